@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pokemium/worldwide/pkg/emulator/audio"
+	"github.com/pokemium/worldwide/pkg/gbs"
+)
+
+// runGBS is `play-gbs` subcommand, it plays or exports GBS file
+func runGBS(args []string) int {
+	fs := flag.NewFlagSet("play-gbs", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n    %s play-gbs [arg] [input]\n    e.g. %s play-gbs -track 3 -wav track3.wav ./music.gbs\nArguments: \n", title, title)
+		fs.PrintDefaults()
+	}
+	var (
+		track  = fs.Int("track", 0, "track number (default: first track in GBS header)")
+		all    = fs.Bool("all", false, "play all tracks from -track")
+		length = fs.Float64("length", 150, "track length (sec), 0 means endless")
+		fade   = fs.Float64("fade", 8, "fade-out length (sec)")
+		wav    = fs.String("wav", "", "export track into WAV file instead of playing (track number is appended with -all)")
+		rate   = fs.Int("rate", audio.SampleRate, "audio sample rate (Hz)")
+	)
+	fs.Parse(args)
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "GBS Error: %s\n", err)
+		return ExitCodeError
+	}
+	g, err := gbs.Parse(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "GBS Error: %s\n", err)
+		return ExitCodeError
+	}
+
+	first := *track
+	if first == 0 {
+		first = g.FirstSong
+	}
+	if first < 1 || first > g.Songs {
+		fmt.Fprintf(os.Stderr, "GBS Error: track must be 1..%d\n", g.Songs)
+		return ExitCodeError
+	}
+	if *wav != "" && *length <= 0 {
+		fmt.Fprintf(os.Stderr, "GBS Error: -length is needed to export WAV\n")
+		return ExitCodeError
+	}
+	last := first
+	if *all {
+		last = g.Songs
+	}
+
+	fmt.Printf("%s - %s (%s)\n", g.Title, g.Author, g.Copyright)
+	if *wav == "" {
+		audio.SampleRate, audio.Sync = *rate, true
+		if err := audio.Open(); err != nil {
+			fmt.Fprintf(os.Stderr, "Audio Error: %s\n", err)
+			return ExitCodeError
+		}
+		defer audio.Close()
+		enable := true
+		audio.Reset(&enable)
+	}
+
+	for song := first; song <= last; song++ {
+		p, err := g.NewPlayer(song, *rate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "GBS Error: %s\n", err)
+			return ExitCodeError
+		}
+		p.SetLength(*length, *fade)
+		fmt.Printf("Track %d/%d\n", song, g.Songs)
+
+		if *wav != "" {
+			path := *wav
+			if *all {
+				path = fmt.Sprintf("%s-%02d.wav", strings.TrimSuffix(path, filepath.Ext(path)), song)
+			}
+			err = exportGBS(p, path, *rate)
+		} else {
+			err = playGBS(p)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "GBS Error: %s\n", err)
+			return ExitCodeError
+		}
+	}
+	return ExitCodeOK
+}
+
+func exportGBS(p *gbs.Player, path string, rate int) error {
+	w, err := audio.CreateWAV(path, rate, 2)
+	if err != nil {
+		return err
+	}
+	for {
+		samples, ok, err := p.Frame()
+		if err != nil || !ok {
+			w.Close()
+			return err
+		}
+		if err := w.Write(samples); err != nil {
+			w.Close()
+			return err
+		}
+	}
+}
+
+// playGBS plays a track, audio.Sync makes speed follow audio output
+func playGBS(p *gbs.Player) error {
+	for {
+		samples, ok, err := p.Frame()
+		if err != nil || !ok {
+			return err
+		}
+		audio.SetStream(samples)
+		audio.Play()
+	}
+}
@@ -7,9 +7,12 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/pokemium/worldwide/pkg/emulator"
+	"github.com/pokemium/worldwide/pkg/emulator/audio"
+	"github.com/pokemium/worldwide/pkg/emulator/joypad"
 )
 
 var version string
@@ -31,10 +34,11 @@ func init() {
 	flag.Usage = func() {
 		usage := fmt.Sprintf(`Usage:
     %s [arg] [input]
+    %s play-gbs [arg] [input]
     e.g. %s -p 8888 ./PM_PRISM.gbc
-Input: ROM filepath, ***.gb or ***.gbc
+Input: ROM filepath, ***.gb or ***.gbc (GBS filepath for play-gbs)
 Arguments: 
-`, title, title)
+`, title, title, title)
 		fmt.Println(Version())
 		fmt.Fprint(os.Stderr, usage)
 		flag.PrintDefaults()
@@ -47,9 +51,23 @@ func main() {
 
 // Run program
 func Run() int {
+	if len(os.Args) > 1 && os.Args[1] == "play-gbs" {
+		return runGBS(os.Args[2:])
+	}
+
 	var (
 		showVersion = flag.Bool("v", false, "show version")
 		port        = flag.Int("p", 0, "HTTP server port (>1023)")
+		keymap      = flag.String("keymap", "", "key bindings file (JSON)")
+		rate        = flag.Int("rate", audio.SampleRate, "audio sample rate (Hz)")
+		latency     = flag.Int("latency", audio.Latency, "target audio latency (ms)")
+		audioSync   = flag.Bool("audiosync", false, "sync emulation speed to audio instead of adjusting audio rate")
+		sink        = flag.String("audio", audio.Sink, "audio output ("+strings.Join(audio.Sinks, ", ")+")")
+		output      = flag.String("audioout", "", "output file of wav and pipe audio output (pipe default: stdout)")
+		wav         = flag.String("wav", "", "record audio into WAV file")
+		stems       = flag.Bool("stems", false, "also record each sound channel into WAV files (with -wav)")
+		vgm         = flag.String("vgm", "", "log sound register writes into VGM file")
+		midi        = flag.String("midi", "", "export sound channels into MIDI file")
 	)
 
 	flag.Parse()
@@ -59,6 +77,29 @@ func Run() int {
 		return ExitCodeOK
 	}
 
+	if *keymap != "" {
+		if err := joypad.LoadConfig(*keymap); err != nil {
+			fmt.Fprintf(os.Stderr, "Keymap Error: %s\n", err)
+			return ExitCodeError
+		}
+	}
+
+	if *rate < 8000 || *rate > 192000 {
+		fmt.Fprintf(os.Stderr, "Audio Error: sample rate must be 8000..192000\n")
+		return ExitCodeError
+	}
+	if *latency < 10 || *latency > 1000 {
+		fmt.Fprintf(os.Stderr, "Audio Error: latency must be 10..1000\n")
+		return ExitCodeError
+	}
+	audio.SampleRate, audio.Latency, audio.Sync = *rate, *latency, *audioSync
+	audio.Sink, audio.Output = *sink, *output
+	if err := audio.Open(); err != nil {
+		fmt.Fprintf(os.Stderr, "Audio Error: %s\n", err)
+		return ExitCodeError
+	}
+	defer audio.Close()
+
 	romPath := flag.Arg(0)
 	cur, _ := os.Getwd()
 
@@ -69,7 +110,29 @@ func Run() int {
 		return ExitCodeError
 	}
 
-	emu := emulator.New(romData, romDir)
+	emu, err := emulator.New(romData, romDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ROM Error: %s\n", err)
+		return ExitCodeError
+	}
+	if *wav != "" {
+		if err := emu.StartRecording(*wav, *stems); err != nil {
+			fmt.Fprintf(os.Stderr, "WAV Error: %s\n", err)
+			return ExitCodeError
+		}
+	}
+	if *vgm != "" {
+		if err := emu.StartVGM(*vgm); err != nil {
+			fmt.Fprintf(os.Stderr, "VGM Error: %s\n", err)
+			return ExitCodeError
+		}
+	}
+	if *midi != "" {
+		if err := emu.StartMIDI(*midi); err != nil {
+			fmt.Fprintf(os.Stderr, "MIDI Error: %s\n", err)
+			return ExitCodeError
+		}
+	}
 	if *port > 0 {
 		if *port < 1024 {
 			fmt.Fprintf(os.Stderr, "Server Error: cannot use well-known port for server")
@@ -83,14 +146,13 @@ func Run() int {
 		os.Chdir(cur)
 	}()
 
-	if err := ebiten.RunGame(emu); err != nil {
-		if err.Error() == "quit" {
-			emu.Exit()
-			return ExitCodeOK
-		}
+	// Exit finalizes WAV, VGM and MIDI files also on emulation error
+	err = ebiten.RunGame(emu)
+	emu.Exit()
+	if err != nil && err.Error() != "quit" {
+		fmt.Fprintf(os.Stderr, "Emulation Error: %s\n", err)
 		return ExitCodeError
 	}
-	emu.Exit()
 	return ExitCodeOK
 }
 
@@ -1,32 +1,196 @@
 package audio
 
 import (
-	"github.com/hajimehoshi/oto"
+	"log"
+	"sync/atomic"
+
 	"github.com/pokemium/worldwide/pkg/gbc/apu"
 )
 
-var context *oto.Context
-var player *oto.Player
-var Stream []byte
+const (
+	maxRateDelta = 0.01 // dynamic rate control changes resampling ratio up to 1%
+	chunkSec     = 120  // audio goroutine passes 1/120 sec to sink at once
+)
+
+var sink AudioSink
+var sinkName atomic.Value // current sink name, it is changed by audio goroutine on fallback
 var enable *bool
 
-func Reset(enablePtr *bool) {
-	enable = enablePtr
+// Sink is sink name opened by Open(oto, null, wav, pipe)
+var Sink = "oto"
+
+// Output is output file of wav and pipe sink
+var Output string
+
+// SampleRate is host sample rate, APU output is resampled into this rate
+var SampleRate = apu.SAMPLE_RATE
+
+// Latency is target queued audio(ms), dynamic rate control keeps queue around this
+var Latency = 60
+
+// Sync makes emulation wait for audio instead of adjusting resampling ratio
+var Sync bool
+
+// buffer is between emulator(producer) and audio goroutine(consumer)
+var (
+	buffer  *ring
+	stream  []int16       // latest frame, passed to buffer by Play
+	drained chan struct{} // audio goroutine notifies that buffer has room, used by Sync
+	quit    chan struct{}
+	done    chan struct{}
+
+	playing   int32
+	underruns int64
+	overruns  int64
+)
+
+// Stats is audio buffer statistics for latency tuning
+type Stats struct {
+	Sink       string  `json:"sink"`
+	SampleRate int     `json:"sample_rate"`
+	Fill       int     `json:"fill"`   // queued samples
+	Target     int     `json:"target"` // target queued samples
+	Ratio      float64 `json:"ratio"`  // resampling ratio by dynamic rate control
+	Sync       bool    `json:"sync"`
+	Underruns  int     `json:"underruns"` // queue got empty while playing
+	Overruns   int     `json:"overruns"`  // queue got full and new samples were dropped
+}
 
-	var err error
-	context, err = oto.NewContext(apu.SAMPLE_RATE, 2, 1, apu.SAMPLE_RATE/apu.BUF_SEC)
+// Open opens Sink and starts audio goroutine
+//
+// It falls back to null sink if audio device is unavailable(e.g. headless machine).
+func Open() error {
+	s, err := OpenSink(Sink, Output, SampleRate)
 	if err != nil {
-		panic(err)
+		if Sink != "oto" {
+			return err
+		}
+		log.Printf("audio device is unavailable, fall back to null sink: %v\n", err)
+		s = newNullSink(SampleRate)
 	}
 
-	player = context.NewPlayer()
+	sink = s
+	sinkName.Store(s.Name())
+	buffer = newRing(target() * 2 * 4)
+	drained = make(chan struct{}, 1)
+	quit, done = make(chan struct{}), make(chan struct{})
+	go pull()
+	return nil
+}
+
+// Close stops audio goroutine and closes sink
+func Close() error {
+	if sink == nil {
+		return nil
+	}
+	close(quit)
+	<-done
+	err := sink.Close()
+	sink = nil
+	return err
 }
 
+// Reset binds sound enable flag of current APU
+func Reset(enablePtr *bool) { enable = enablePtr }
+
+// pull is audio callback, it takes samples from buffer whenever sink consumes a chunk and passes silence on underrun
+func pull() {
+	s := sink
+	defer func() {
+		sink = s
+		close(done)
+	}()
+
+	samples := make([]int16, SampleRate/chunkSec*2)
+	for {
+		select {
+		case <-quit:
+			return
+		default:
+		}
+
+		n := buffer.Read(samples)
+		if n < len(samples) && atomic.CompareAndSwapInt32(&playing, 1, 0) {
+			atomic.AddInt64(&underruns, 1)
+		}
+		select {
+		case drained <- struct{}{}:
+		default:
+		}
+
+		for i := n; i < len(samples); i++ {
+			samples[i] = 0
+		}
+		if err := s.Write(samples); err != nil {
+			// e.g. named pipe is closed by reader
+			log.Printf("audio sink error, fall back to null sink: %v\n", err)
+			s.Close()
+			s = newNullSink(SampleRate)
+			sinkName.Store(s.Name())
+		}
+	}
+}
+
+// Play passes latest frame to audio goroutine, it doesn't block unless Sync
 func Play() {
-	if player == nil || !*enable {
+	if sink == nil || enable == nil || !*enable {
 		return
 	}
-	player.Write(Stream)
+
+	if Sync {
+		for buffer.Len() > target()*2 {
+			<-drained
+		}
+	}
+
+	if n := buffer.Write(stream); n < len(stream) {
+		atomic.AddInt64(&overruns, 1)
+	}
+	atomic.StoreInt32(&playing, 1)
 }
 
-func SetStream(b []byte) { Stream = b }
+// SetStream keeps signed 16bit interleaved stereo samples of latest frame until Play
+func SetStream(samples []int16) { stream = append(stream[:0], samples...) }
+
+// target returns target queued samples
+func target() int { return SampleRate * Latency / 1000 }
+
+// fill returns queued samples
+func fill() int {
+	if buffer == nil {
+		return 0
+	}
+	return buffer.Len() / 2
+}
+
+// Ratio returns resampling ratio which keeps queue around target
+//
+// APU generates a little more samples when queue is shorter than target, and vice versa.
+func Ratio() float64 {
+	if Sync {
+		return 1
+	}
+
+	ratio := 1 + maxRateDelta*float64(target()-fill())/float64(target())
+	if ratio < 1-maxRateDelta {
+		ratio = 1 - maxRateDelta
+	}
+	if ratio > 1+maxRateDelta {
+		ratio = 1 + maxRateDelta
+	}
+	return ratio
+}
+
+func GetStats() Stats {
+	name, _ := sinkName.Load().(string)
+	return Stats{
+		Sink:       name,
+		SampleRate: SampleRate,
+		Fill:       fill(),
+		Target:     target(),
+		Ratio:      Ratio(),
+		Sync:       Sync,
+		Underruns:  int(atomic.LoadInt64(&underruns)),
+		Overruns:   int(atomic.LoadInt64(&overruns)),
+	}
+}
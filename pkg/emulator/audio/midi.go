@@ -0,0 +1,252 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/pokemium/worldwide/pkg/gbc/apu"
+)
+
+const (
+	midiDivision = 480    // ticks per quarter note
+	midiTempo    = 500000 // microseconds per quarter note(120bpm)
+	midiTicks    = midiDivision * 1000000 / midiTempo
+
+	drumChannel = 9
+)
+
+var (
+	trackNames   = [4]string{"Square 1", "Square 2", "Wave", "Noise"}
+	midiPrograms = [4]byte{80, 80, 81, 0} // Lead 1(square), Lead 1(square), Lead 2(sawtooth), drum kit
+)
+
+// MIDI converts sound channel events into Standard MIDI File(format 1)
+//
+// Each channel is a track, and noise channel is mapped to percussion.
+// Notes are started on trigger and pitch change, stopped by length counter or volume 0.
+// Envelope is expressed as expression(CC 11).
+type MIDI struct {
+	mu     sync.Mutex
+	f      *os.File
+	name   string
+	start  uint64 // APU clocks on start
+	tracks [4][]midiEvent
+	notes  [4]int  // playing note, -1 means no note
+	expr   [4]byte // current expression
+}
+
+type midiEvent struct {
+	tick uint64
+	data []byte
+}
+
+func NewMIDI(path string, cycles uint64) (*MIDI, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &MIDI{f: f, name: path, start: cycles}
+	for i := range m.notes {
+		m.notes[i] = -1
+		m.expr[i] = 0xff
+		if i != 3 {
+			m.add(i, 0, 0xc0|byte(i), midiPrograms[i]) // program change
+		}
+	}
+	return m, nil
+}
+
+// Write is called on APU register write, s is the state after the write
+func (m *MIDI) Write(cycles uint64, offset, value byte, s apu.State) {
+	var trigger [4]bool
+	switch offset {
+	case 0x14, 0x19, 0x1e, 0x23:
+		trigger[(offset-0x14)/5] = value&0x80 != 0
+	}
+	m.update(cycles, s, trigger)
+}
+
+// Poll catches events not caused by register writes(length counter, envelope, sweep)
+func (m *MIDI) Poll(cycles uint64, s apu.State) {
+	m.update(cycles, s, [4]bool{})
+}
+
+func (m *MIDI) update(cycles uint64, s apu.State, trigger [4]bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.f == nil {
+		return
+	}
+
+	tick := (cycles - m.start) * midiTicks / apu.CLOCK
+	for i, c := range s.Channels {
+		note, volume := channelNote(i, c)
+		if note >= 0 && i < 3 && volume != m.expr[i] {
+			m.add(i, tick, 0xb0|byte(i), 11, volume) // expression
+			m.expr[i] = volume
+		}
+
+		switch {
+		case note < 0:
+			m.noteOff(i, tick)
+		case trigger[i]:
+			m.noteOff(i, tick)
+			m.noteOn(i, tick, note, volume)
+		case i < 3 && note != m.notes[i]:
+			// pitch is changed by sweep or frequency register write without trigger
+			m.noteOff(i, tick)
+			m.noteOn(i, tick, note, volume)
+		}
+	}
+}
+
+// channelNote returns MIDI note number(-1 means silent) and volume(0..127)
+func channelNote(i int, c apu.ChannelState) (int, byte) {
+	if !c.Enabled || !c.DAC || c.Hz <= 0 {
+		return -1, 0
+	}
+
+	volume := byte(c.Volume * 127 / 15)
+	if i == 2 {
+		volume = [4]byte{0, 127, 64, 32}[c.Volume]
+	}
+	if volume == 0 {
+		return -1, 0
+	}
+
+	if i == 3 {
+		return drumNote(c), volume
+	}
+
+	// note pitch from 11bit frequency register
+	note := int(math.Round(69 + 12*math.Log2(c.Hz/440)))
+	if note < 0 || note > 127 {
+		return -1, 0
+	}
+	return note, volume
+}
+
+// drumNote maps noise into General MIDI percussion
+func drumNote(c apu.ChannelState) int {
+	switch {
+	case c.Noise != nil && c.Noise.Width == 7:
+		return 56 // cowbell, metallic tone
+	case c.Hz >= 65536:
+		return 42 // closed hi-hat
+	case c.Hz >= 8192:
+		return 38 // snare
+	default:
+		return 36 // bass drum
+	}
+}
+
+func (m *MIDI) midiChannel(i int) byte {
+	if i == 3 {
+		return drumChannel
+	}
+	return byte(i)
+}
+
+// noteOn starts note, volume is used as velocity only on drum because melodic channels use expression
+func (m *MIDI) noteOn(i int, tick uint64, note int, volume byte) {
+	velocity := byte(100)
+	if i == 3 {
+		velocity = volume
+	}
+	m.add(i, tick, 0x90|m.midiChannel(i), byte(note), velocity)
+	m.notes[i] = note
+}
+
+func (m *MIDI) noteOff(i int, tick uint64) {
+	if m.notes[i] < 0 {
+		return
+	}
+	m.add(i, tick, 0x80|m.midiChannel(i), byte(m.notes[i]), 0)
+	m.notes[i] = -1
+}
+
+func (m *MIDI) add(i int, tick uint64, data ...byte) {
+	m.tracks[i] = append(m.tracks[i], midiEvent{tick, data})
+}
+
+// Close writes MIDI file
+func (m *MIDI) Close(cycles uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.f == nil {
+		return nil
+	}
+
+	tick := (cycles - m.start) * midiTicks / apu.CLOCK
+	for i := range m.tracks {
+		m.noteOff(i, tick)
+	}
+
+	// header chunk
+	buf := make([]byte, 14)
+	copy(buf, "MThd")
+	binary.BigEndian.PutUint32(buf[4:], 6)
+	binary.BigEndian.PutUint16(buf[8:], 1) // format 1
+	binary.BigEndian.PutUint16(buf[10:], uint16(len(m.tracks)+1))
+	binary.BigEndian.PutUint16(buf[12:], midiDivision)
+
+	// conductor track
+	tempo := []midiEvent{{0, []byte{0xff, 0x51, 0x03, midiTempo >> 16 & 0xff, midiTempo >> 8 & 0xff, midiTempo & 0xff}}}
+	buf = appendTrack(buf, "", tempo, tick)
+	for i, events := range m.tracks {
+		buf = appendTrack(buf, trackNames[i], events, tick)
+	}
+
+	_, err := m.f.Write(buf)
+	if e := m.f.Close(); err == nil {
+		err = e
+	}
+	m.f = nil
+	return err
+}
+
+func appendTrack(buf []byte, name string, events []midiEvent, end uint64) []byte {
+	var data []byte
+	if name != "" {
+		data = append(data, 0x00, 0xff, 0x03, byte(len(name)))
+		data = append(data, name...)
+	}
+
+	last := uint64(0)
+	for _, e := range events {
+		data = appendVarLen(data, e.tick-last)
+		data = append(data, e.data...)
+		last = e.tick
+	}
+	data = appendVarLen(data, end-last)
+	data = append(data, 0xff, 0x2f, 0x00) // end of track
+
+	header := make([]byte, 8)
+	copy(header, "MTrk")
+	binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+	return append(append(buf, header...), data...)
+}
+
+// appendVarLen appends variable-length quantity
+func appendVarLen(buf []byte, v uint64) []byte {
+	var tmp [10]byte
+	i := len(tmp) - 1
+	tmp[i] = byte(v & 0x7f)
+	for v >>= 7; v > 0; v >>= 7 {
+		i--
+		tmp[i] = byte(v&0x7f) | 0x80
+	}
+	return append(buf, tmp[i:]...)
+}
+
+// Rebase keeps timestamps continuous when APU is replaced(e.g. on reset), old is the last clocks of old APU
+func (m *MIDI) Rebase(old, new uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.start += new - old
+}
+
+func (m *MIDI) Name() string { return m.name }
@@ -0,0 +1,62 @@
+package audio
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pokemium/worldwide/pkg/gbc/apu"
+)
+
+// examples from Standard MIDI File specification
+func TestAppendVarLen(t *testing.T) {
+	for _, tt := range []struct {
+		v    uint64
+		want []byte
+	}{
+		{0x00, []byte{0x00}},
+		{0x40, []byte{0x40}},
+		{0x7f, []byte{0x7f}},
+		{0x80, []byte{0x81, 0x00}},
+		{0x2000, []byte{0xc0, 0x00}},
+		{0x3fff, []byte{0xff, 0x7f}},
+		{0x4000, []byte{0x81, 0x80, 0x00}},
+		{0x100000, []byte{0xc0, 0x80, 0x00}},
+		{0x1fffff, []byte{0xff, 0xff, 0x7f}},
+		{0x200000, []byte{0x81, 0x80, 0x80, 0x00}},
+		{0x8000000, []byte{0xc0, 0x80, 0x80, 0x00}},
+		{0xfffffff, []byte{0xff, 0xff, 0xff, 0x7f}},
+	} {
+		if got := appendVarLen(nil, tt.v); !bytes.Equal(got, tt.want) {
+			t.Errorf("0x%x: got % x, want % x", tt.v, got, tt.want)
+		}
+	}
+
+	// appends to existing buffer
+	if got := appendVarLen([]byte{0xaa}, 0x80); !bytes.Equal(got, []byte{0xaa, 0x81, 0x00}) {
+		t.Errorf("append: got % x", got)
+	}
+}
+
+func TestChannelNote(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		i      int
+		c      apu.ChannelState
+		note   int
+		volume byte
+	}{
+		{"A4", 0, apu.ChannelState{Enabled: true, DAC: true, Hz: 440, Volume: 15}, 69, 127},
+		{"C5 half volume", 1, apu.ChannelState{Enabled: true, DAC: true, Hz: 523.25, Volume: 7}, 72, 59},
+		{"wave 50%", 2, apu.ChannelState{Enabled: true, DAC: true, Hz: 220, Volume: 2}, 57, 64},
+		{"disabled", 0, apu.ChannelState{DAC: true, Hz: 440, Volume: 15}, -1, 0},
+		{"DAC off", 0, apu.ChannelState{Enabled: true, Hz: 440, Volume: 15}, -1, 0},
+		{"volume 0", 0, apu.ChannelState{Enabled: true, DAC: true, Hz: 440}, -1, 0},
+		{"too low", 0, apu.ChannelState{Enabled: true, DAC: true, Hz: 1, Volume: 15}, -1, 0},
+		{"snare", 3, apu.ChannelState{Enabled: true, DAC: true, Hz: 16384, Volume: 15}, 38, 127},
+	} {
+		note, volume := channelNote(tt.i, tt.c)
+		if note != tt.note || volume != tt.volume {
+			t.Errorf("%s: got %d, %d, want %d, %d", tt.name, note, volume, tt.note, tt.volume)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package audio
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Recorder writes APU output into WAV files, mixed output and optionally each channel stem(`xxx_chn1.wav`..`xxx_chn4.wav`)
+type Recorder struct {
+	mu    sync.Mutex
+	mix   *WAV
+	stems []*WAV
+}
+
+func NewRecorder(path string, rate int, stems bool) (*Recorder, error) {
+	mix, err := CreateWAV(path, rate, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{mix: mix}
+	if stems {
+		base := strings.TrimSuffix(path, filepath.Ext(path))
+		for i := 1; i <= 4; i++ {
+			stem, err := CreateWAV(fmt.Sprintf("%s_chn%d.wav", base, i), rate, 2)
+			if err != nil {
+				r.Close()
+				return nil, err
+			}
+			r.stems = append(r.stems, stem)
+		}
+	}
+	return r, nil
+}
+
+// Write is passed to apu.APU.SetRecord
+func (r *Recorder) Write(mix []int16, stems [4][]int16) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.mix == nil {
+		return
+	}
+
+	r.mix.Write(mix)
+	for i, stem := range r.stems {
+		stem.Write(stems[i])
+	}
+}
+
+func (r *Recorder) Stems() bool { return len(r.stems) > 0 }
+
+// Files returns recorded file names
+func (r *Recorder) Files() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.mix == nil {
+		return nil
+	}
+
+	files := []string{r.mix.Name()}
+	for _, stem := range r.stems {
+		files = append(files, stem.Name())
+	}
+	return files
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.mix == nil {
+		return nil
+	}
+
+	err := r.mix.Close()
+	for _, stem := range r.stems {
+		if e := stem.Close(); err == nil {
+			err = e
+		}
+	}
+	r.mix, r.stems = nil, nil
+	return err
+}
@@ -0,0 +1,58 @@
+package audio
+
+import "sync/atomic"
+
+// ring is lock-free single-producer/single-consumer buffer of samples
+//
+// Emulator goroutine writes and audio goroutine reads, so neither waits for the other.
+type ring struct {
+	read  uint64 // updated only by consumer
+	write uint64 // updated only by producer
+	mask  uint64
+	buf   []int16
+}
+
+// newRing allocates ring, size is rounded up to power of 2
+func newRing(size int) *ring {
+	n := 1
+	for n < size {
+		n <<= 1
+	}
+	return &ring{mask: uint64(n - 1), buf: make([]int16, n)}
+}
+
+// Len returns samples ready to read
+func (r *ring) Len() int {
+	return int(atomic.LoadUint64(&r.write) - atomic.LoadUint64(&r.read))
+}
+
+// Free returns samples which can be written
+func (r *ring) Free() int { return len(r.buf) - r.Len() }
+
+// Write copies samples as many as possible and returns written samples, only producer calls this
+func (r *ring) Write(p []int16) int {
+	w := atomic.LoadUint64(&r.write)
+	if free := len(r.buf) - int(w-atomic.LoadUint64(&r.read)); len(p) > free {
+		p = p[:free]
+	}
+
+	start := int(w & r.mask)
+	n := copy(r.buf[start:], p)
+	copy(r.buf, p[n:])
+	atomic.StoreUint64(&r.write, w+uint64(len(p)))
+	return len(p)
+}
+
+// Read copies samples as many as possible and returns read samples, only consumer calls this
+func (r *ring) Read(p []int16) int {
+	rd := atomic.LoadUint64(&r.read)
+	if avail := int(atomic.LoadUint64(&r.write) - rd); len(p) > avail {
+		p = p[:avail]
+	}
+
+	start := int(rd & r.mask)
+	n := copy(p, r.buf[start:])
+	copy(p[n:], r.buf)
+	atomic.StoreUint64(&r.read, rd+uint64(len(p)))
+	return len(p)
+}
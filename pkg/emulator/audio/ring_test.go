@@ -0,0 +1,118 @@
+package audio
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestRingSize(t *testing.T) {
+	for _, tt := range []struct{ size, want int }{{1, 1}, {5, 8}, {8, 8}, {1000, 1024}} {
+		if got := len(newRing(tt.size).buf); got != tt.want {
+			t.Errorf("newRing(%d): size = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestRingWraparound(t *testing.T) {
+	r := newRing(8)
+	next, expected := int16(0), int16(0)
+	in, out := make([]int16, 5), make([]int16, 5)
+
+	// 5 samples in and out move indices across the end of 8 samples buffer
+	for i := 0; i < 100; i++ {
+		for j := range in {
+			in[j] = next + int16(j)
+		}
+		if n := r.Write(in); n != len(in) {
+			t.Fatalf("#%d: Write = %d, want %d", i, n, len(in))
+		}
+		next += int16(len(in))
+		if r.Len() != 5 || r.Free() != 3 {
+			t.Fatalf("#%d: Len, Free = %d, %d, want 5, 3", i, r.Len(), r.Free())
+		}
+
+		if n := r.Read(out); n != len(out) {
+			t.Fatalf("#%d: Read = %d, want %d", i, n, len(out))
+		}
+		for _, s := range out {
+			if s != expected {
+				t.Fatalf("#%d: got %d, want %d", i, s, expected)
+			}
+			expected++
+		}
+	}
+}
+
+func TestRingFullEmpty(t *testing.T) {
+	r := newRing(8)
+	if n := r.Read(make([]int16, 4)); n != 0 {
+		t.Errorf("Read on empty = %d, want 0", n)
+	}
+
+	// write is truncated when full
+	in := []int16{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	if n := r.Write(in); n != 8 {
+		t.Errorf("Write = %d, want 8", n)
+	}
+	if n := r.Write(in); n != 0 {
+		t.Errorf("Write on full = %d, want 0", n)
+	}
+
+	// read is truncated when empty
+	out := make([]int16, 10)
+	if n := r.Read(out); n != 8 {
+		t.Errorf("Read = %d, want 8", n)
+	}
+	for i, s := range out[:8] {
+		if s != in[i] {
+			t.Errorf("out[%d] = %d, want %d", i, s, in[i])
+		}
+	}
+}
+
+// TestRingConcurrent runs producer and consumer like emulator and audio goroutine, run with -race
+func TestRingConcurrent(t *testing.T) {
+	const total = 100000
+	r := newRing(1024)
+	done := make(chan struct{})
+
+	// consumer keeps reading after mismatch so that producer doesn't wait forever
+	go func() {
+		defer close(done)
+		out := make([]int16, 333)
+		expected, errors := int16(0), 0
+		for received := 0; received < total; {
+			n := r.Read(out)
+			for _, s := range out[:n] {
+				if s != expected && errors < 10 {
+					t.Errorf("sample %d: got %d, want %d", received, s, expected)
+					errors++
+				}
+				expected = s + 1
+			}
+			received += n
+			if n == 0 {
+				runtime.Gosched()
+			}
+		}
+	}()
+
+	in := make([]int16, 257)
+	next := int16(0)
+	for sent := 0; sent < total; {
+		m := len(in)
+		if total-sent < m {
+			m = total - sent
+		}
+		for i := 0; i < m; i++ {
+			in[i] = next + int16(i)
+		}
+		n := r.Write(in[:m])
+		next += int16(n)
+		sent += n
+		if n == 0 {
+			runtime.Gosched()
+		}
+	}
+	<-done
+}
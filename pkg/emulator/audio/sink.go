@@ -0,0 +1,162 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hajimehoshi/oto"
+	"github.com/pokemium/worldwide/pkg/gbc/apu"
+)
+
+// AudioSink is where audio goroutine outputs samples
+type AudioSink interface {
+	// Write outputs signed 16bit interleaved stereo samples
+	//
+	// It blocks until sink accepts samples, so sink decides playback speed.
+	Write(samples []int16) error
+	Close() error
+	Name() string
+}
+
+// Sinks is sink names for OpenSink
+var Sinks = []string{"oto", "null", "wav", "pipe"}
+
+// OpenSink opens sink by name
+//
+// path is output file of wav and pipe sink, "-" means stdout on pipe sink.
+func OpenSink(name, path string, rate int) (AudioSink, error) {
+	switch name {
+	case "oto":
+		return newOtoSink(rate)
+	case "null":
+		return newNullSink(rate), nil
+	case "wav":
+		if path == "" {
+			return nil, fmt.Errorf("wav sink needs output file")
+		}
+		w, err := CreateWAV(path, rate, 2)
+		if err != nil {
+			return nil, err
+		}
+		return &wavSink{clock: clock{rate: rate}, w: w}, nil
+	case "pipe":
+		if path == "" || path == "-" {
+			return &pipeSink{f: os.Stdout}, nil
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return &pipeSink{f: f}, nil
+	}
+	return nil, fmt.Errorf("unknown audio sink: %s", name)
+}
+
+// clock makes sink without audio device consume samples in real time
+type clock struct {
+	rate int
+	next time.Time
+}
+
+func (c *clock) wait(samples int) {
+	now := time.Now()
+	if now.Sub(c.next) > time.Second {
+		// e.g. process was suspended, don't catch up
+		c.next = now
+	}
+	c.next = c.next.Add(time.Duration(samples/2) * time.Second / time.Duration(c.rate))
+	time.Sleep(time.Until(c.next))
+}
+
+// otoSink outputs to audio device
+type otoSink struct {
+	context *oto.Context
+	player  *oto.Player
+	buf     []byte
+}
+
+func newOtoSink(rate int) (*otoSink, error) {
+	// signed 16bit stereo
+	context, err := oto.NewContext(rate, 2, 2, rate*4/apu.BUF_SEC)
+	if err != nil {
+		return nil, err
+	}
+	return &otoSink{context: context, player: context.NewPlayer()}, nil
+}
+
+func (s *otoSink) Write(samples []int16) error {
+	s.buf = encode(s.buf, samples)
+	_, err := s.player.Write(s.buf)
+	return err
+}
+
+func (s *otoSink) Close() error {
+	s.player.Close()
+	return s.context.Close()
+}
+
+func (s *otoSink) Name() string { return "oto" }
+
+// nullSink discards samples, it is used on headless machine
+type nullSink struct{ clock }
+
+func newNullSink(rate int) *nullSink { return &nullSink{clock{rate: rate}} }
+
+func (s *nullSink) Write(samples []int16) error {
+	s.wait(len(samples))
+	return nil
+}
+
+func (s *nullSink) Close() error { return nil }
+func (s *nullSink) Name() string { return "null" }
+
+// wavSink writes what would be played into WAV file, including silence on underrun
+type wavSink struct {
+	clock
+	w *WAV
+}
+
+func (s *wavSink) Write(samples []int16) error {
+	s.wait(len(samples))
+	return s.w.Write(samples)
+}
+
+func (s *wavSink) Close() error { return s.w.Close() }
+func (s *wavSink) Name() string { return "wav" }
+
+// pipeSink writes raw PCM(signed 16bit little-endian stereo), reader decides playback speed
+//
+// e.g. ./worldwide -audio pipe "***.gb" | aplay -f S16_LE -c 2 -r 44100
+type pipeSink struct {
+	f   *os.File
+	buf []byte
+}
+
+func (s *pipeSink) Write(samples []int16) error {
+	s.buf = encode(s.buf, samples)
+	_, err := s.f.Write(s.buf)
+	return err
+}
+
+func (s *pipeSink) Close() error {
+	if s.f == os.Stdout {
+		return nil
+	}
+	return s.f.Close()
+}
+
+func (s *pipeSink) Name() string { return "pipe" }
+
+// encode converts samples into little-endian bytes, buf is reused
+func encode(buf []byte, samples []int16) []byte {
+	if cap(buf) < len(samples)*2 {
+		buf = make([]byte, len(samples)*2)
+	}
+	buf = buf[:len(samples)*2]
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(buf[i*2:], uint16(s))
+	}
+	return buf
+}
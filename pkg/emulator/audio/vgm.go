@@ -0,0 +1,138 @@
+package audio
+
+import (
+	"encoding/binary"
+	"os"
+	"sync"
+
+	"github.com/pokemium/worldwide/pkg/gbc/apu"
+)
+
+const (
+	vgmRate       = 44100 // VGM wait commands are always in 44100Hz samples
+	vgmHeaderSize = 0x100
+)
+
+// VGM logs APU register writes into VGM 1.71 file
+//
+// ref: https://vgmrips.net/wiki/VGM_Specification
+type VGM struct {
+	mu          sync.Mutex
+	f           *os.File
+	name        string
+	data        []byte // command stream
+	start       uint64 // APU clocks on start
+	samples     uint64 // total samples of wait commands
+	loopOffset  int    // offset in data, -1 means no loop
+	loopSamples uint64
+}
+
+// NewVGM starts logging, init is register writes to reproduce current APU state(apu.APU.InitWrites)
+func NewVGM(path string, cycles uint64, init [][2]byte) (*VGM, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &VGM{f: f, name: path, start: cycles, loopOffset: -1}
+	for _, w := range init {
+		v.command(w[0], w[1])
+	}
+	return v, nil
+}
+
+// Write is passed to apu.APU.OnWrite
+func (v *VGM) Write(cycles uint64, offset, value byte) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.f == nil {
+		return
+	}
+
+	v.waitUntil(cycles)
+	v.command(offset, value)
+}
+
+// Loop sets loop point at current time
+func (v *VGM) Loop(cycles uint64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.f == nil {
+		return
+	}
+
+	v.waitUntil(cycles)
+	v.loopOffset, v.loopSamples = len(v.data), v.samples
+}
+
+// 0xB3 aa dd: GameBoy DMG write value dd to register aa(0x00 = NR10)
+func (v *VGM) command(offset, value byte) {
+	v.data = append(v.data, 0xb3, offset-0x10, value)
+}
+
+func (v *VGM) waitUntil(cycles uint64) {
+	target := (cycles - v.start) * vgmRate / apu.CLOCK
+	for n := target - v.samples; n > 0; {
+		switch {
+		case n <= 16:
+			v.data = append(v.data, 0x70+byte(n-1)) // 0x7n: wait n+1 samples
+			v.samples += n
+			return
+		case n == 735:
+			v.data = append(v.data, 0x62) // wait 1/60 sec
+		case n == 882:
+			v.data = append(v.data, 0x63) // wait 1/50 sec
+		default:
+			w := n
+			if w > 0xffff {
+				w = 0xffff
+			}
+			v.data = append(v.data, 0x61, byte(w), byte(w>>8))
+			v.samples += w
+			n -= w
+			continue
+		}
+		v.samples += n
+		return
+	}
+}
+
+// Close writes VGM file
+func (v *VGM) Close(cycles uint64) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.f == nil {
+		return nil
+	}
+
+	v.waitUntil(cycles)
+	v.data = append(v.data, 0x66) // end of sound data
+
+	header := make([]byte, vgmHeaderSize)
+	copy(header[0x00:], "Vgm ")
+	binary.LittleEndian.PutUint32(header[0x04:], uint32(vgmHeaderSize+len(v.data)-0x04)) // EOF offset
+	binary.LittleEndian.PutUint32(header[0x08:], 0x171)                                  // version
+	binary.LittleEndian.PutUint32(header[0x18:], uint32(v.samples))                      // total samples
+	if v.loopOffset >= 0 {
+		binary.LittleEndian.PutUint32(header[0x1c:], uint32(vgmHeaderSize+v.loopOffset-0x1c))
+		binary.LittleEndian.PutUint32(header[0x20:], uint32(v.samples-v.loopSamples))
+	}
+	binary.LittleEndian.PutUint32(header[0x34:], vgmHeaderSize-0x34) // VGM data offset
+	binary.LittleEndian.PutUint32(header[0x80:], apu.CLOCK)          // GameBoy DMG clock
+
+	_, err := v.f.Write(append(header, v.data...))
+	if e := v.f.Close(); err == nil {
+		err = e
+	}
+	v.f = nil
+	return err
+}
+
+// Rebase keeps timestamps continuous when APU is replaced(e.g. on reset), old is the last clocks of old APU
+func (v *VGM) Rebase(old, new uint64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.start += new - old
+}
+
+func (v *VGM) Name() string { return v.name }
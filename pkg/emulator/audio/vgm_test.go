@@ -0,0 +1,106 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pokemium/worldwide/pkg/gbc/apu"
+)
+
+// vgmCycles returns APU clocks which is exactly n samples of VGM
+func vgmCycles(n uint64) uint64 { return (n*apu.CLOCK + vgmRate - 1) / vgmRate }
+
+func TestVGMWait(t *testing.T) {
+	for _, tt := range []struct {
+		samples uint64
+		want    []byte
+	}{
+		{0, nil},
+		{1, []byte{0x70}},
+		{16, []byte{0x7f}},
+		{17, []byte{0x61, 0x11, 0x00}},
+		{735, []byte{0x62}},
+		{882, []byte{0x63}},
+		{1000, []byte{0x61, 0xe8, 0x03}},
+		{0xffff, []byte{0x61, 0xff, 0xff}},
+		{0x10000, []byte{0x61, 0xff, 0xff, 0x70}},
+		{0xffff + 735, []byte{0x61, 0xff, 0xff, 0x62}},
+		{0xffff*2 + 20, []byte{0x61, 0xff, 0xff, 0x61, 0xff, 0xff, 0x61, 0x14, 0x00}},
+	} {
+		v := &VGM{loopOffset: -1}
+		v.waitUntil(vgmCycles(tt.samples))
+		if !bytes.Equal(v.data, tt.want) {
+			t.Errorf("%d samples: got % x, want % x", tt.samples, v.data, tt.want)
+		}
+		if v.samples != tt.samples {
+			t.Errorf("%d samples: total = %d", tt.samples, v.samples)
+		}
+	}
+}
+
+// TestVGMWaitAccumulate checks waits don't drift on many small intervals
+func TestVGMWaitAccumulate(t *testing.T) {
+	v := &VGM{loopOffset: -1}
+	cycles := uint64(0)
+	for i := 0; i < 10000; i++ {
+		cycles += 1234
+		v.waitUntil(cycles)
+	}
+	if want := cycles * vgmRate / apu.CLOCK; v.samples != want {
+		t.Errorf("total = %d, want %d", v.samples, want)
+	}
+}
+
+func TestVGMFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.vgm")
+	v, err := NewVGM(path, 1000, [][2]byte{{0x26, 0x80}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Write(1000+vgmCycles(735), 0x12, 0xf0)
+	v.Loop(1000 + vgmCycles(735*2))
+	v.Write(1000+vgmCycles(735*3), 0x14, 0x87)
+	if err := v.Close(1000 + vgmCycles(735*4)); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0xb3, 0x16, 0x80, // NR52
+		0x62, 0xb3, 0x02, 0xf0, // NR12
+		0x62,                   // wait until loop point
+		0x62, 0xb3, 0x04, 0x87, // NR14 after loop point
+		0x62, 0x66,
+	}
+	if !bytes.Equal(data[vgmHeaderSize:], want) {
+		t.Errorf("data = % x, want % x", data[vgmHeaderSize:], want)
+	}
+
+	header := func(offset int) uint32 { return binary.LittleEndian.Uint32(data[offset:]) }
+	if string(data[:4]) != "Vgm " {
+		t.Errorf("ident = %q", data[:4])
+	}
+	for _, tt := range []struct {
+		name   string
+		offset int
+		want   uint32
+	}{
+		{"EOF offset", 0x04, uint32(len(data) - 0x04)},
+		{"version", 0x08, 0x171},
+		{"total samples", 0x18, 735 * 4},
+		{"loop offset", 0x1c, vgmHeaderSize + 8 - 0x1c},
+		{"loop samples", 0x20, 735 * 2},
+		{"data offset", 0x34, vgmHeaderSize - 0x34},
+		{"GB clock", 0x80, apu.CLOCK},
+	} {
+		if got := header(tt.offset); got != tt.want {
+			t.Errorf("%s = 0x%x, want 0x%x", tt.name, got, tt.want)
+		}
+	}
+}
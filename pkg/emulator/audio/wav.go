@@ -0,0 +1,70 @@
+package audio
+
+import (
+	"encoding/binary"
+	"os"
+)
+
+// WAV is signed 16bit PCM wave file writer
+type WAV struct {
+	f        *os.File
+	channels int
+	rate     int
+	size     uint32 // data chunk size
+}
+
+func CreateWAV(path string, rate, channels int) (*WAV, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &WAV{f: f, channels: channels, rate: rate}
+	if err := w.writeHeader(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// writeHeader writes RIFF header, chunk sizes are fixed on Close
+func (w *WAV) writeHeader() error {
+	header := make([]byte, 44)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], 36+w.size)
+	copy(header[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	binary.LittleEndian.PutUint16(header[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(header[22:], uint16(w.channels))
+	binary.LittleEndian.PutUint32(header[24:], uint32(w.rate))
+	binary.LittleEndian.PutUint32(header[28:], uint32(w.rate*w.channels*2))
+	binary.LittleEndian.PutUint16(header[32:], uint16(w.channels*2))
+	binary.LittleEndian.PutUint16(header[34:], 16)
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], w.size)
+
+	_, err := w.f.WriteAt(header, 0)
+	return err
+}
+
+// Write appends interleaved samples
+func (w *WAV) Write(samples []int16) error {
+	buf := make([]byte, len(samples)*2)
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(buf[i*2:], uint16(s))
+	}
+
+	n, err := w.f.WriteAt(buf, 44+int64(w.size))
+	w.size += uint32(n)
+	return err
+}
+
+func (w *WAV) Close() error {
+	if err := w.writeHeader(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+func (w *WAV) Name() string { return w.f.Name() }
@@ -0,0 +1,61 @@
+package emulator
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pokemium/worldwide/pkg/gbc"
+	"github.com/pokemium/worldwide/pkg/util"
+)
+
+// crash writes savefile and crash report on emulation error, then the error stops the game loop
+func (e *Emulator) crash(err error) error {
+	log.Println(err)
+	e.writeSav()
+
+	base := filepath.Join(e.RomDir, fmt.Sprintf("%s-crash-%s", e.GBC.Cartridge.Title, time.Now().Format("20060102-150405")))
+	if werr := os.WriteFile(base+".txt", []byte(e.crashReport(err)), 0644); werr != nil {
+		log.Printf("crash report error: %v\n", werr)
+	} else {
+		log.Printf("crash report is written in %s.txt\n", base)
+	}
+	if werr := e.writeDisplay(base + ".png"); werr != nil {
+		log.Printf("crash report error: %v\n", werr)
+	}
+	return err
+}
+
+// crashReport contains registers, recent instructions and Go stack trace
+func (e *Emulator) crashReport(err error) string {
+	g := e.GBC
+	r := g.Reg
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n", err)
+	fmt.Fprintf(&b, "ROM: %s\n\n", g.Cartridge.Title)
+
+	fmt.Fprintf(&b, "Registers\n")
+	fmt.Fprintf(&b, "A: 0x%02x F: 0x%02x\n", r.R[gbc.A], r.R[gbc.F])
+	fmt.Fprintf(&b, "B: 0x%02x C: 0x%02x\n", r.R[gbc.B], r.R[gbc.C])
+	fmt.Fprintf(&b, "D: 0x%02x E: 0x%02x\n", r.R[gbc.D], r.R[gbc.E])
+	fmt.Fprintf(&b, "H: 0x%02x L: 0x%02x\n", r.R[gbc.H], r.R[gbc.L])
+	fmt.Fprintf(&b, "PC: 0x%04x SP: 0x%04x\n", r.PC, r.SP)
+	fmt.Fprintf(&b, "IE: 0x%02x IF: 0x%02x IME: %d\n", g.IO[gbc.IEIO], g.IO[gbc.IFIO], util.Bool2U8(r.IME))
+	fmt.Fprintf(&b, "Halt: %v Stop: %v DoubleSpeed: %v\n\n", g.Halt, g.Stop, g.DoubleSpeed)
+
+	fmt.Fprintf(&b, "Recent instructions\n")
+	for _, inst := range g.RecentInsts() {
+		fmt.Fprintf(&b, "0x%04x: 0x%02x\n", inst.PC, inst.Opcode)
+	}
+
+	var fault *gbc.Fault
+	if errors.As(err, &fault) && len(fault.Stack) > 0 {
+		fmt.Fprintf(&b, "\nStack trace\n%s", fault.Stack)
+	}
+	return b.String()
+}
@@ -0,0 +1,106 @@
+package debug
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"math/cmplx"
+	"time"
+
+	"github.com/pokemium/worldwide/pkg/gbc/apu"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	scopeLen = 512  // waveform points per channel
+	fftLen   = 1024 // mixed spectrum is fftLen/2 bins
+)
+
+// AudioFrame is sent to /debug/audio every frame
+type AudioFrame struct {
+	Rate     int          `json:"rate"`     // sample rate of waveforms
+	Channels [4][]float32 `json:"channels"` // -1..1
+	Spectrum []float32    `json:"spectrum"` // dB, bin width is rate/1024
+}
+
+// Audio streams each sound channel's waveform and mixed spectrum
+func (d *Debugger) Audio(ws *websocket.Conn) {
+	d.addAudioClient(1)
+	defer d.addAudioClient(-1)
+
+	for range time.NewTicker(time.Second / 60).C {
+		channels, mix := d.g.Sound.Scope()
+		if mix == nil {
+			// scope is enabled on next frame
+			continue
+		}
+
+		frame := AudioFrame{Rate: apu.ScopeRate, Spectrum: spectrum(mix[len(mix)-fftLen:])}
+		for i, ch := range channels {
+			frame.Channels[i] = ch[len(ch)-scopeLen:]
+		}
+
+		res, err := json.Marshal(frame)
+		if err != nil {
+			log.Printf("error encoding data: %v\n", err)
+			return
+		}
+		if err := websocket.Message.Send(ws, string(res)); err != nil {
+			log.Printf("error sending data: %v\n", err)
+			return
+		}
+	}
+}
+
+// addAudioClient updates connections and scope on/off at once, so last disconnect can't disable scope after new connect
+func (d *Debugger) addAudioClient(delta int) {
+	d.audioMu.Lock()
+	defer d.audioMu.Unlock()
+	d.audioClients += delta
+	d.g.Sound.EnableScope(d.audioClients > 0)
+}
+
+// spectrum returns magnitude(dB) of samples with hann window
+func spectrum(samples []float32) []float32 {
+	n := len(samples)
+	x := make([]complex128, n)
+	for i, s := range samples {
+		w := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+		x[i] = complex(float64(s)*w, 0)
+	}
+	fft(x)
+
+	result := make([]float32, n/2)
+	for i := range result {
+		mag := cmplx.Abs(x[i]) / float64(n/4) // hann window halves amplitude
+		result[i] = float32(20 * math.Log10(mag+1e-9))
+	}
+	return result
+}
+
+// fft is in-place radix-2 FFT, len(x) must be power of 2
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		w := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			wk := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], x[start+k+size/2]*wk
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				wk *= w
+			}
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/pokemium/worldwide/pkg/gbc"
 )
@@ -20,6 +21,12 @@ type Debugger struct {
 	pause       *bool
 	Breakpoints []uint16
 	history     []gbc.CurInst
+
+	violations   []gbc.Violation
+	violationMax int
+
+	audioMu      sync.Mutex // guards audioClients and scope on/off together
+	audioClients int        // /debug/audio connections
 }
 
 func New(g *gbc.GBC, pause *bool) *Debugger {
@@ -30,7 +37,10 @@ func New(g *gbc.GBC, pause *bool) *Debugger {
 }
 
 func (d *Debugger) Reset(g *gbc.GBC) {
+	d.audioMu.Lock()
 	d.g = g
+	g.Sound.EnableScope(d.audioClients > 0)
+	d.audioMu.Unlock()
 	d.history = make([]gbc.CurInst, len(d.history))
 }
 
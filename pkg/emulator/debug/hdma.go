@@ -0,0 +1,39 @@
+package debug
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type HDMA struct {
+	State     string `json:"state"`
+	Mode      string `json:"mode"`
+	Src       string `json:"src"`
+	Dest      string `json:"dest"`
+	Remaining int    `json:"remaining"`
+}
+
+// HDMA returns CGB HDMA transfer state
+func (d *Debugger) HDMA(w http.ResponseWriter, req *http.Request) {
+	s := d.g.HDMA()
+	h := HDMA{
+		State:     s.State,
+		Mode:      "general",
+		Src:       fmt.Sprintf("0x%04x", s.Src),
+		Dest:      fmt.Sprintf("0x%04x", s.Dest),
+		Remaining: s.Remaining,
+	}
+	if s.HBlank {
+		h.Mode = "hblank"
+	}
+
+	res, err := json.Marshal(h)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
@@ -8,14 +8,14 @@ import (
 )
 
 func (d *Debugger) IO(ws *websocket.Conn) {
-	err := websocket.Message.Send(ws, d.g.IO[:])
+	err := websocket.Message.Send(ws, d.g.IOSnapshot())
 	if err != nil {
 		log.Printf("error sending data: %v\n", err)
 		return
 	}
 
 	for range time.NewTicker(time.Millisecond * 100).C {
-		err := websocket.Message.Send(ws, d.g.IO[:])
+		err := websocket.Message.Send(ws, d.g.IOSnapshot())
 		if err != nil {
 			log.Printf("error sending data: %v\n", err)
 			return
@@ -0,0 +1,34 @@
+package debug
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type Lockup struct {
+	Locked bool   `json:"locked"`
+	PC     string `json:"pc,omitempty"`
+	Opcode string `json:"opcode,omitempty"`
+}
+
+// Lockup returns where CPU is locked up by illegal opcode
+func (d *Debugger) Lockup(w http.ResponseWriter, req *http.Request) {
+	l := Lockup{}
+	if lockup := d.g.Lockup; lockup != nil {
+		l = Lockup{
+			Locked: true,
+			PC:     fmt.Sprintf("0x%04x", lockup.PC),
+			Opcode: fmt.Sprintf("0x%02x", lockup.Opcode),
+		}
+	}
+
+	res, err := json.Marshal(l)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
@@ -26,6 +26,7 @@ type Register struct {
 	IE, IF      string
 	IME         string
 	Halt        string
+	Stop        string
 	DoubleSpeed string
 }
 
@@ -58,6 +59,7 @@ func (d *Debugger) Register(w http.ResponseWriter, req *http.Request) {
 			IF:          fmt.Sprintf("0x%02x", d.g.IO[gbc.IFIO]),
 			IME:         fmt.Sprintf("0x%02x", util.Bool2U8(d.g.Reg.IME)),
 			Halt:        fmt.Sprintf("%+v", d.g.Halt),
+			Stop:        fmt.Sprintf("%+v", d.g.Stop),
 			DoubleSpeed: fmt.Sprintf("%+v", d.g.DoubleSpeed),
 		}
 
@@ -30,7 +30,10 @@ func (d *Debugger) Trace(w http.ResponseWriter, req *http.Request) {
 
 		result := ""
 		for s := uint16(0); s < steps; s++ {
-			d.g.Step()
+			if err := d.g.Step(); err != nil {
+				result += err.Error() + "\n"
+				break
+			}
 			for _, callback := range d.g.Callbacks {
 				if callback.Priority == util.PRIO_BREAKPOINT {
 					continue
@@ -0,0 +1,52 @@
+package debug
+
+import (
+	"net/http"
+
+	"github.com/pokemium/worldwide/pkg/gbc"
+)
+
+func (d *Debugger) Violation(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+		d.getViolations(w, req)
+	case "POST":
+		d.postViolation(w, req)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (d *Debugger) getViolations(w http.ResponseWriter, req *http.Request) {
+	result := ""
+	for _, v := range d.violations {
+		result += v.String() + "\n"
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(result))
+}
+
+func (d *Debugger) postViolation(w http.ResponseWriter, req *http.Request) {
+	length := getU16FromQuery(w, req, "violation")
+	d.violations = []gbc.Violation{}
+	if length == 0 {
+		d.violationMax = 0
+		d.g.OnViolation = nil
+		return
+	}
+	if length > 100 {
+		http.Error(w, "violation's count cannot be greater than 100", http.StatusBadRequest)
+		return
+	}
+
+	d.violationMax = int(length)
+	d.g.OnViolation = d.putViolation
+}
+
+// putViolation records the latest VRAM/OAM/palette accesses which are blocked on real hardware
+func (d *Debugger) putViolation(v gbc.Violation) {
+	if len(d.violations) == d.violationMax {
+		d.violations = d.violations[1:]
+	}
+	d.violations = append(d.violations, v)
+}
@@ -3,6 +3,7 @@ package emulator
 import (
 	"errors"
 	"fmt"
+	"log"
 	"os"
 	"os/signal"
 	"syscall"
@@ -25,13 +26,27 @@ type Emulator struct {
 	Rom      []byte
 	RomDir   string
 	debugger *debug.Debugger
+	recorder *audio.Recorder // WAV recording, nil unless recording
+	vgm      *audio.VGM      // VGM logging, nil unless logging
+	midi     *audio.MIDI     // MIDI export, nil unless exporting
 	pause    bool
 	reset    bool
 	quit     bool
+	locked   bool // CPU lockup is already reported
+
+	speed, fastForward float64 // emulation speed multiplier, SpeedUnlimited(0) means unlimited
+	frameDebt          float64 // fractional frames carried over to next tick
+	advance            int     // frames to run on pause state
+
+	tasks chan func() // requests from HTTP server, they are run between frames
 }
 
-func New(romData []byte, romDir string) *Emulator {
-	g := gbc.New(romData, joypad.Handler, audio.SetStream)
+func New(romData []byte, romDir string) (*Emulator, error) {
+	g, err := gbc.New(romData, joypad.Handler, audio.SetStream)
+	if err != nil {
+		return nil, err
+	}
+	g.Sound.SetSampleRate(audio.SampleRate)
 	audio.Reset(&g.Sound.Enable)
 
 	ebiten.SetWindowResizable(true)
@@ -39,67 +54,119 @@ func New(romData []byte, romDir string) *Emulator {
 	ebiten.SetWindowSize(160*2, 144*2)
 
 	e := &Emulator{
-		GBC:    g,
-		Rom:    romData,
-		RomDir: romDir,
+		GBC:         g,
+		Rom:         romData,
+		RomDir:      romDir,
+		speed:       1,
+		fastForward: 4,
+		tasks:       make(chan func()),
 	}
+	g.Sound.OnWrite = e.onSoundWrite
 	e.debugger = debug.New(g, &e.pause)
 	e.setupCloseHandler()
 
 	e.loadSav()
-	return e
+	return e, nil
 }
 
-func (e *Emulator) ResetGBC() {
+func (e *Emulator) ResetGBC() error {
 	e.writeSav()
 
-	oldCallbacks := e.GBC.Callbacks
-	e.GBC = gbc.New(e.Rom, joypad.Handler, audio.SetStream)
-	e.GBC.Callbacks = oldCallbacks
+	g, err := gbc.New(e.Rom, joypad.Handler, audio.SetStream)
+	if err != nil {
+		return err
+	}
+	g.Callbacks = e.GBC.Callbacks
+	g.Sound.SetSampleRate(audio.SampleRate)
+	g.Sound.CopyMixer(e.GBC.Sound)
+	audio.Reset(&g.Sound.Enable)
+	if e.recorder != nil {
+		g.Sound.SetRecord(e.recorder.Write, e.recorder.Stems())
+	}
+	g.Sound.OnWrite = e.onSoundWrite
+	if e.vgm != nil {
+		e.vgm.Rebase(e.GBC.Sound.Cycles(), g.Sound.Cycles())
+	}
+	if e.midi != nil {
+		e.midi.Rebase(e.GBC.Sound.Cycles(), g.Sound.Cycles())
+	}
+	g.OnViolation = e.GBC.OnViolation
+	e.GBC = g
 
 	e.debugger.Reset(e.GBC)
 	e.loadSav()
+	e.locked = false
 
 	e.reset = false
+	return nil
 }
 
 func (e *Emulator) Update() error {
 	if e.quit {
 		return errors.New("quit")
 	}
+	e.runTasks()
+	e.handleHotkeys(joypad.Poll())
 	if e.reset {
-		e.ResetGBC()
-		return nil
+		return e.ResetGBC()
 	}
 	if e.pause {
+		if e.advance > 0 {
+			if err := e.advanceFrame(); err != nil {
+				return e.crash(err)
+			}
+		}
 		return nil
 	}
 
-	defer e.GBC.PanicHandler("update", true)
-	e.GBC.Update()
+	speed := e.currentSpeed()
+	if err := e.runFrames(e.framesToRun(speed), speed); err != nil {
+		return e.crash(err)
+	}
 	if e.pause {
 		return nil
 	}
 
-	audio.Play()
+	// audio is skipped on fast-forward and slow motion
+	if speed == 1 {
+		audio.Play()
+		e.GBC.Sound.SetRatio(audio.Ratio())
+	}
 
 	select {
 	case <-second.C:
 		e.GBC.RTC.IncrementSecond()
-		ebiten.SetWindowTitle(fmt.Sprintf("%dfps", int(ebiten.CurrentTPS())))
+		title := fmt.Sprintf("%dfps", int(ebiten.CurrentTPS()))
+		if speed != 1 {
+			title += fmt.Sprintf(" x%s", speedString(speed))
+		}
+		if e.GBC.Lockup != nil {
+			title += " " + e.GBC.Lockup.String()
+		}
+		ebiten.SetWindowTitle(title)
 	default:
 	}
 
 	return nil
 }
 
+// checkLockup pauses emulator when CPU is locked up by illegal opcode
+func (e *Emulator) checkLockup() {
+	if e.GBC.Lockup == nil || e.locked {
+		return
+	}
+	e.locked = true
+	e.pause = true
+	log.Println(e.GBC.Lockup)
+	ebiten.SetWindowTitle(e.GBC.Lockup.String())
+}
+
 func (e *Emulator) Draw(screen *ebiten.Image) {
 	if e.pause {
 		screen.ReplacePixels(cache)
 		return
 	}
 
-	defer e.GBC.PanicHandler("draw", true)
 	cache = e.GBC.Draw()
 	screen.ReplacePixels(cache)
 }
@@ -110,14 +177,38 @@ func (e *Emulator) Layout(outsideWidth, outsideHeight int) (screenWidth, screenH
 
 func (e *Emulator) Exit() {
 	e.writeSav()
+	e.StopRecording()
+	e.StopVGM()
+	e.StopMIDI()
 }
 
+// quit emulator on SIGINT, SIGTERM, then savefile is written by Exit
 func (e *Emulator) setupCloseHandler() {
-	c := make(chan os.Signal)
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
-		e.Exit()
-		os.Exit(0)
+		e.quit = true
 	}()
 }
+
+// runTask runs f between frames and waits for it, HTTP handlers use this not to change emulator state while running
+func (e *Emulator) runTask(f func()) {
+	done := make(chan struct{})
+	e.tasks <- func() {
+		f()
+		close(done)
+	}
+	<-done
+}
+
+func (e *Emulator) runTasks() {
+	for {
+		select {
+		case f := <-e.tasks:
+			f()
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package emulator
+
+import (
+	"fmt"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pokemium/worldwide/pkg/emulator/joypad"
+)
+
+func (e *Emulator) handleHotkeys(pressed []joypad.Hotkey) {
+	for _, h := range pressed {
+		switch h {
+		case joypad.HotkeyPause:
+			e.pause = !e.pause
+		case joypad.HotkeyFrameAdvance:
+			e.pause = true
+			e.advance++
+		case joypad.HotkeyReset:
+			e.reset = true
+		case joypad.HotkeyScreenshot:
+			if err := e.screenshot(); err != nil {
+				log.Printf("screenshot error: %v\n", err)
+			}
+		case joypad.HotkeyMuteCh1, joypad.HotkeyMuteCh2, joypad.HotkeyMuteCh3, joypad.HotkeyMuteCh4:
+			e.GBC.Sound.ToggleSoundChannel(int(h[len(h)-1] - '0'))
+		case joypad.HotkeySoloCh1, joypad.HotkeySoloCh2, joypad.HotkeySoloCh3, joypad.HotkeySoloCh4:
+			e.toggleSolo(int(h[len(h)-1] - '0'))
+		}
+	}
+}
+
+// screenshot is saved in ROM directory as png
+func (e *Emulator) screenshot() error {
+	name := fmt.Sprintf("%s-%s.png", e.GBC.Cartridge.Title, time.Now().Format("20060102-150405"))
+	return e.writeDisplay(filepath.Join(e.RomDir, name))
+}
+
+func (e *Emulator) writeDisplay(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return png.Encode(f, e.GBC.Video.Display())
+}
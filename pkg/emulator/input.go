@@ -0,0 +1,138 @@
+package emulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pokemium/worldwide/pkg/emulator/joypad"
+	"golang.org/x/net/websocket"
+)
+
+// InputMessage is received on input websocket
+//
+// e.g. {"action":"press","button":"a"}, {"action":"hold","button":"start","frames":10}
+type InputMessage struct {
+	Action string `json:"action"` // press, release, hold, releaseall
+	Button string `json:"button"`
+	Frames int    `json:"frames"`
+}
+
+func (e *Emulator) pressButton(w http.ResponseWriter, req *http.Request) {
+	btn, err := joypad.ButtonID(req.URL.Query().Get("button"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	joypad.Press(btn)
+}
+
+func (e *Emulator) releaseButton(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Query().Get("button") == "all" {
+		joypad.ReleaseAll()
+		return
+	}
+
+	btn, err := joypad.ButtonID(req.URL.Query().Get("button"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	joypad.Release(btn)
+}
+
+func (e *Emulator) holdButton(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	btn, err := joypad.ButtonID(q.Get("button"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	frames, err := strconv.Atoi(q.Get("frames"))
+	if err != nil || frames <= 0 {
+		http.Error(w, "`frames` is needed on query parameter(e.g. ?frames=10)", http.StatusBadRequest)
+		return
+	}
+	joypad.Hold(btn, frames)
+}
+
+// InputWS receives InputMessage continuously, this is lower latency than HTTP API.
+func (e *Emulator) InputWS(ws *websocket.Conn) {
+	defer joypad.ReleaseAll()
+
+	for {
+		var msg InputMessage
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			log.Printf("error receiving data: %v\n", err)
+			return
+		}
+
+		if err := handleInputMessage(msg); err != nil {
+			websocket.Message.Send(ws, err.Error())
+		}
+	}
+}
+
+func handleInputMessage(msg InputMessage) error {
+	action := strings.ToLower(msg.Action)
+	if action == "releaseall" {
+		joypad.ReleaseAll()
+		return nil
+	}
+
+	btn, err := joypad.ButtonID(msg.Button)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "press":
+		joypad.Press(btn)
+	case "release":
+		joypad.Release(btn)
+	case "hold":
+		if msg.Frames <= 0 {
+			return fmt.Errorf("frames must be positive on hold action")
+		}
+		joypad.Hold(btn, msg.Frames)
+	default:
+		return fmt.Errorf("invalid action: %s", msg.Action)
+	}
+	return nil
+}
+
+// InputConfig gets or replaces key bindings in JSON format.
+func (e *Emulator) InputConfig(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+		res, err := json.Marshal(joypad.CurrentConfig())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(res)
+	case "POST":
+		body, _ := io.ReadAll(req.Body)
+		c, err := joypad.ParseConfig(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		joypad.SetConfig(c)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// reloadInputConfig rereads key bindings file
+func (e *Emulator) reloadInputConfig(w http.ResponseWriter, req *http.Request) {
+	if err := joypad.ReloadConfig(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,187 @@
+package joypad
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Hotkey is emulator function triggered by input
+type Hotkey string
+
+const (
+	HotkeyPause        Hotkey = "pause"
+	HotkeyReset        Hotkey = "reset"
+	HotkeyFastForward  Hotkey = "fastforward"
+	HotkeyFrameAdvance Hotkey = "frame_advance"
+	HotkeyScreenshot   Hotkey = "screenshot"
+	HotkeyMuteCh1      Hotkey = "mute_ch1"
+	HotkeyMuteCh2      Hotkey = "mute_ch2"
+	HotkeyMuteCh3      Hotkey = "mute_ch3"
+	HotkeyMuteCh4      Hotkey = "mute_ch4"
+	HotkeySoloCh1      Hotkey = "solo_ch1"
+	HotkeySoloCh2      Hotkey = "solo_ch2"
+	HotkeySoloCh3      Hotkey = "solo_ch3"
+	HotkeySoloCh4      Hotkey = "solo_ch4"
+)
+
+var hotkeys = []Hotkey{
+	HotkeyPause, HotkeyReset, HotkeyFastForward, HotkeyFrameAdvance, HotkeyScreenshot,
+	HotkeyMuteCh1, HotkeyMuteCh2, HotkeyMuteCh3, HotkeyMuteCh4, HotkeySoloCh1, HotkeySoloCh2, HotkeySoloCh3, HotkeySoloCh4,
+}
+
+// Axis is gamepad stick input
+//
+// Direction is -1(left, up) or 1(right, down)
+type Axis struct {
+	Axis      int     `json:"axis"`
+	Direction float64 `json:"direction"`
+}
+
+// Binding is a set of host inputs, any of them activates the target
+type Binding struct {
+	Keys    []string `json:"keys,omitempty"`
+	Buttons []int    `json:"buttons,omitempty"` // gamepad buttons
+	Axes    []Axis   `json:"axes,omitempty"`
+}
+
+// Config is input mapping, this can be loaded from JSON file.
+//
+// Buttons, Turbo keys are button names(a, b, select, start, right, left, up, down).
+type Config struct {
+	Buttons         map[string]Binding `json:"buttons"`
+	Turbo           map[string]Binding `json:"turbo"`
+	Hotkeys         map[Hotkey]Binding `json:"hotkeys"`
+	Deadzone        float64            `json:"deadzone"`
+	TurboPeriod     int                `json:"turbo_period"` // turbo button is on for n frames and off for n frames
+	PreventOpposite bool               `json:"prevent_opposite"`
+}
+
+// DefaultConfig is same as worldwide's original key bindings.
+func DefaultConfig() Config {
+	return Config{
+		Buttons: map[string]Binding{
+			"a":      {Keys: []string{"X"}, Buttons: []int{1}},
+			"b":      {Keys: []string{"Z"}, Buttons: []int{0}},
+			"select": {Keys: []string{"Backspace"}, Buttons: []int{6}},
+			"start":  {Keys: []string{"Enter"}, Buttons: []int{7}},
+			"right":  {Keys: []string{"Right"}, Buttons: []int{12}, Axes: []Axis{{0, 1}}},
+			"left":   {Keys: []string{"Left"}, Buttons: []int{14}, Axes: []Axis{{0, -1}}},
+			"up":     {Keys: []string{"Up"}, Buttons: []int{11}, Axes: []Axis{{1, -1}}},
+			"down":   {Keys: []string{"Down"}, Buttons: []int{13}, Axes: []Axis{{1, 1}}},
+		},
+		Turbo: map[string]Binding{
+			"a": {Keys: []string{"S"}, Buttons: []int{3}},
+			"b": {Keys: []string{"A"}, Buttons: []int{2}},
+		},
+		Hotkeys: map[Hotkey]Binding{
+			HotkeyPause:        {Keys: []string{"P"}},
+			HotkeyReset:        {Keys: []string{"R"}},
+			HotkeyFastForward:  {Keys: []string{"Tab"}},
+			HotkeyFrameAdvance: {Keys: []string{"N"}},
+			HotkeyScreenshot:   {Keys: []string{"F12"}},
+			HotkeyMuteCh1:      {Keys: []string{"F1"}},
+			HotkeyMuteCh2:      {Keys: []string{"F2"}},
+			HotkeyMuteCh3:      {Keys: []string{"F3"}},
+			HotkeyMuteCh4:      {Keys: []string{"F4"}},
+			HotkeySoloCh1:      {Keys: []string{"F8"}},
+			HotkeySoloCh2:      {Keys: []string{"F9"}},
+			HotkeySoloCh3:      {Keys: []string{"F10"}},
+			HotkeySoloCh4:      {Keys: []string{"F11"}},
+		},
+		Deadzone:        0.3,
+		TurboPeriod:     2,
+		PreventOpposite: true,
+	}
+}
+
+// key name(lower case) -> ebiten.Key
+var keyNames = func() map[string]ebiten.Key {
+	m := map[string]ebiten.Key{}
+	for k := ebiten.Key(0); k <= ebiten.KeyMax; k++ {
+		if name := k.String(); name != "" {
+			m[strings.ToLower(name)] = k
+		}
+	}
+	return m
+}()
+
+// ReadConfig reads input mapping from JSON file.
+//
+// Omitted fields are filled with DefaultConfig.
+func ReadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return ParseConfig(data)
+}
+
+// ParseConfig parses input mapping in JSON format.
+func ParseConfig(data []byte) (Config, error) {
+	c := DefaultConfig()
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Config{}, err
+	}
+	return c, c.validate()
+}
+
+func (c *Config) validate() error {
+	for name, b := range c.Buttons {
+		if _, err := ButtonID(name); err != nil {
+			return err
+		}
+		if err := b.validate(); err != nil {
+			return err
+		}
+	}
+	for name, b := range c.Turbo {
+		if _, err := ButtonID(name); err != nil {
+			return err
+		}
+		if err := b.validate(); err != nil {
+			return err
+		}
+	}
+	for h, b := range c.Hotkeys {
+		if !validHotkey(h) {
+			return fmt.Errorf("invalid hotkey: %s", h)
+		}
+		if err := b.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Deadzone < 0 || c.Deadzone >= 1 {
+		return fmt.Errorf("deadzone must be in [0, 1): %f", c.Deadzone)
+	}
+	if c.TurboPeriod <= 0 {
+		return fmt.Errorf("turbo_period must be positive: %d", c.TurboPeriod)
+	}
+	return nil
+}
+
+func (b *Binding) validate() error {
+	for _, k := range b.Keys {
+		if _, ok := keyNames[strings.ToLower(k)]; !ok {
+			return fmt.Errorf("invalid key name: %s", k)
+		}
+	}
+	for _, a := range b.Axes {
+		if a.Direction != 1 && a.Direction != -1 {
+			return fmt.Errorf("axis direction must be 1 or -1: %f", a.Direction)
+		}
+	}
+	return nil
+}
+
+func validHotkey(h Hotkey) bool {
+	for _, hk := range hotkeys {
+		if h == hk {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package joypad
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// Buttons is button name list, index is same as gbc/joypad's button id
+var Buttons = [8]string{"a", "b", "select", "start", "right", "left", "up", "down"}
+
+var errInvalidButton = errors.New("button must be one of a, b, select, start, right, left, up, down")
+
+// injected button state
+//
+// pressed is kept until Release, holdFrames is decremented by Tick and released on 0
+type injection struct {
+	pressed    bool
+	holdFrames int
+}
+
+var (
+	mu         sync.Mutex
+	injections [8]injection
+)
+
+func ButtonID(name string) (int, error) {
+	name = strings.ToLower(name)
+	for i, b := range Buttons {
+		if b == name {
+			return i, nil
+		}
+	}
+	return 0, errInvalidButton
+}
+
+// Press button until Release is called
+func Press(btn int) {
+	mu.Lock()
+	defer mu.Unlock()
+	injections[btn].pressed = true
+	injections[btn].holdFrames = 0
+}
+
+// Release button pressed by Press or Hold
+func Release(btn int) {
+	mu.Lock()
+	defer mu.Unlock()
+	injections[btn].pressed = false
+	injections[btn].holdFrames = 0
+}
+
+// Hold button for n frames
+func Hold(btn, frames int) {
+	mu.Lock()
+	defer mu.Unlock()
+	injections[btn].pressed = false
+	injections[btn].holdFrames = frames
+}
+
+// ReleaseAll releases all injected buttons
+func ReleaseAll() {
+	mu.Lock()
+	defer mu.Unlock()
+	for i := range injections {
+		injections[i] = injection{}
+	}
+}
+
+// Tick advances hold counters, this is called once per frame.
+func Tick() {
+	mu.Lock()
+	defer mu.Unlock()
+	for i := range injections {
+		if injections[i].holdFrames > 0 {
+			injections[i].holdFrames--
+		}
+	}
+}
+
+func injected(btn int) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return injections[btn].pressed || injections[btn].holdFrames > 0
+}
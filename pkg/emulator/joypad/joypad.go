@@ -1,41 +1,11 @@
 package joypad
 
 import (
-	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/pokemium/worldwide/pkg/gbc/joypad"
 )
 
+// Handler reports GB button state sampled by Poll
 var Handler = [8](func() bool){
-	btnA, btnB, btnSelect, btnStart, keyRight, keyLeft, keyUp, keyDown,
-}
-
-func btnA() bool {
-	return ebiten.IsKeyPressed(ebiten.KeyX)
-}
-
-func btnB() bool {
-	return ebiten.IsKeyPressed(ebiten.KeyZ)
-}
-
-func btnStart() bool {
-	return ebiten.IsKeyPressed(ebiten.KeyEnter)
-}
-
-func btnSelect() bool {
-	return ebiten.IsKeyPressed(ebiten.KeyBackspace)
-}
-
-func keyUp() bool {
-	return ebiten.IsKeyPressed(ebiten.KeyUp)
-}
-
-func keyDown() bool {
-	return ebiten.IsKeyPressed(ebiten.KeyDown)
-}
-
-func keyRight() bool {
-	return ebiten.IsKeyPressed(ebiten.KeyRight)
-}
-
-func keyLeft() bool {
-	return ebiten.IsKeyPressed(ebiten.KeyLeft)
+	pressed(joypad.A), pressed(joypad.B), pressed(joypad.Select), pressed(joypad.Start),
+	pressed(joypad.Right), pressed(joypad.Left), pressed(joypad.Up), pressed(joypad.Down),
 }
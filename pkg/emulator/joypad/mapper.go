@@ -0,0 +1,159 @@
+package joypad
+
+import (
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/pokemium/worldwide/pkg/gbc/joypad"
+)
+
+// mapper converts host input(keyboard, gamepad) into GB buttons and hotkeys using Config
+type mapper struct {
+	mu     sync.Mutex
+	config Config
+	path   string // config file path, empty if default config is used
+
+	frame     int
+	state     [8]bool
+	pressedAt [8]int // frame when button is pressed, this is used to decide which opposite direction wins
+	hotkeys   map[Hotkey]bool
+}
+
+var m = &mapper{
+	config:  DefaultConfig(),
+	hotkeys: map[Hotkey]bool{},
+}
+
+// LoadConfig loads input mapping from JSON file, the file is reread on ReloadConfig.
+func LoadConfig(path string) error {
+	c, err := ReadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config, m.path = c, path
+	return nil
+}
+
+// ReloadConfig rereads config file loaded by LoadConfig.
+func ReloadConfig() error {
+	m.mu.Lock()
+	path := m.path
+	m.mu.Unlock()
+
+	if path == "" {
+		SetConfig(DefaultConfig())
+		return nil
+	}
+	return LoadConfig(path)
+}
+
+// SetConfig replaces input mapping at runtime.
+func SetConfig(c Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config = c
+}
+
+// CurrentConfig returns input mapping in use.
+func CurrentConfig() Config {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.config
+}
+
+// Poll samples host input, this is called once per frame(also on pause).
+//
+// It returns hotkeys which are pressed in this frame.
+func Poll() []Hotkey {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.frame++
+	c := &m.config
+	turboOn := (m.frame/c.TurboPeriod)%2 == 0
+
+	for i, name := range Buttons {
+		pressed := c.Buttons[name].active(c.Deadzone) || injected(i)
+		if !pressed && turboOn {
+			pressed = c.Turbo[name].active(c.Deadzone)
+		}
+
+		if pressed && !m.state[i] {
+			m.pressedAt[i] = m.frame
+		}
+		m.state[i] = pressed
+	}
+
+	if c.PreventOpposite {
+		m.resolveOpposite(joypad.Right, joypad.Left)
+		m.resolveOpposite(joypad.Up, joypad.Down)
+	}
+
+	result := []Hotkey{}
+	for _, h := range hotkeys {
+		held := c.Hotkeys[h].active(c.Deadzone)
+		if held && !m.hotkeys[h] {
+			result = append(result, h)
+		}
+		m.hotkeys[h] = held
+	}
+	return result
+}
+
+// HotkeyHeld reports whether the hotkey is held on last Poll.
+func HotkeyHeld(h Hotkey) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.hotkeys[h]
+}
+
+// newer direction wins if both are pressed
+func (m *mapper) resolveOpposite(d0, d1 int) {
+	if !m.state[d0] || !m.state[d1] {
+		return
+	}
+	if m.pressedAt[d0] > m.pressedAt[d1] {
+		m.state[d1] = false
+		return
+	}
+	m.state[d0] = false
+}
+
+func pressed(btn int) func() bool {
+	return func() bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return m.state[btn]
+	}
+}
+
+func (b Binding) active(deadzone float64) bool {
+	for _, k := range b.Keys {
+		if ebiten.IsKeyPressed(keyNames[strings.ToLower(k)]) {
+			return true
+		}
+	}
+
+	for _, id := range ebiten.GamepadIDs() {
+		for _, btn := range b.Buttons {
+			if ebiten.IsGamepadButtonPressed(id, ebiten.GamepadButton(btn)) {
+				return true
+			}
+		}
+		for _, a := range b.Axes {
+			if a.Axis >= ebiten.GamepadAxisNum(id) {
+				continue
+			}
+			v := ebiten.GamepadAxis(id, a.Axis) * a.Direction
+			if v > 0 && math.Abs(v) > deadzone {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -13,6 +13,26 @@ func (e *Emulator) RunServer(port int) {
 	http.HandleFunc("/reset", e.Reset)
 	http.HandleFunc("/quit", e.Quit)
 	http.HandleFunc("/mute", e.toggleSound)
+	http.HandleFunc("/audio/stats", e.audioStats)
+	http.HandleFunc("/audio/state", e.audioState)
+	http.HandleFunc("/audio/mute", e.muteChannel)
+	http.HandleFunc("/audio/solo", e.soloChannel)
+	http.HandleFunc("/audio/gain", e.gainChannel)
+	http.HandleFunc("/audio/record/start", e.startRecording)
+	http.HandleFunc("/audio/record/stop", e.stopRecording)
+	http.HandleFunc("/audio/vgm/start", e.startVGM)
+	http.HandleFunc("/audio/vgm/loop", e.loopVGM)
+	http.HandleFunc("/audio/vgm/stop", e.stopVGM)
+	http.HandleFunc("/audio/midi/start", e.startMIDI)
+	http.HandleFunc("/audio/midi/stop", e.stopMIDI)
+	http.HandleFunc("/speed", e.Speed)
+	http.HandleFunc("/frameadvance", e.FrameAdvance)
+	http.HandleFunc("/joypad/press", e.pressButton)
+	http.HandleFunc("/joypad/release", e.releaseButton)
+	http.HandleFunc("/joypad/hold", e.holdButton)
+	http.Handle("/joypad", websocket.Handler(e.InputWS))
+	http.HandleFunc("/joypad/config", e.InputConfig)
+	http.HandleFunc("/joypad/config/reload", e.reloadInputConfig)
 	http.HandleFunc("/debug/register", e.debugger.Register)
 	http.HandleFunc("/debug/break", e.debugger.Break)
 	http.HandleFunc("/debug/cartridge", e.debugger.Cartridge)
@@ -21,10 +41,14 @@ func (e *Emulator) RunServer(port int) {
 	http.HandleFunc("/debug/disasm", e.debugger.Disasm)
 	http.HandleFunc("/debug/trace", e.debugger.Trace)
 	http.HandleFunc("/debug/history", e.debugger.Hisotry)
+	http.HandleFunc("/debug/lockup", e.debugger.Lockup)
+	http.HandleFunc("/debug/violation", e.debugger.Violation)
+	http.HandleFunc("/debug/hdma", e.debugger.HDMA)
 	http.Handle("/debug/tileview/bank0", websocket.Handler(e.debugger.TileView0))
 	http.Handle("/debug/tileview/bank1", websocket.Handler(e.debugger.TileView1))
 	http.Handle("/debug/sprview", websocket.Handler(e.debugger.SprView))
 	http.Handle("/debug/io", websocket.Handler(e.debugger.IO))
+	http.Handle("/debug/audio", websocket.Handler(e.debugger.Audio))
 	http.ListenAndServe(fmt.Sprintf(":%d", port), nil)
 }
 
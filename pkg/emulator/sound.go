@@ -0,0 +1,342 @@
+package emulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pokemium/worldwide/pkg/emulator/audio"
+	"github.com/pokemium/worldwide/pkg/gbc/apu"
+)
+
+// audioStats returns audio buffer fill and underrun counts
+func (e *Emulator) audioStats(w http.ResponseWriter, req *http.Request) {
+	res, err := json.Marshal(audio.GetStats())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+// StartRecording starts recording APU output into WAV file, if stems is true, each channel is also recorded into `xxx_chn1.wav`..`xxx_chn4.wav`
+//
+// If path is empty, it's saved in ROM directory. This must be called on emulator goroutine(e.g. by runTask).
+func (e *Emulator) StartRecording(path string, stems bool) error {
+	e.StopRecording()
+	if path == "" {
+		name := fmt.Sprintf("%s-%s.wav", e.GBC.Cartridge.Title, time.Now().Format("20060102-150405"))
+		path = filepath.Join(e.RomDir, name)
+	}
+
+	r, err := audio.NewRecorder(path, e.GBC.Sound.SampleRate(), stems)
+	if err != nil {
+		return err
+	}
+	e.recorder = r
+	e.GBC.Sound.SetRecord(r.Write, stems)
+	return nil
+}
+
+// StopRecording stops WAV recording and returns recorded files
+func (e *Emulator) StopRecording() ([]string, error) {
+	if e.recorder == nil {
+		return nil, nil
+	}
+
+	e.GBC.Sound.SetRecord(nil, false)
+	files := e.recorder.Files()
+	err := e.recorder.Close()
+	e.recorder = nil
+	return files, err
+}
+
+// StartVGM starts logging APU register writes into VGM file, if path is empty, it's saved in ROM directory.
+//
+// This must be called on emulator goroutine(e.g. by runTask).
+func (e *Emulator) StartVGM(path string) error {
+	e.StopVGM()
+	if path == "" {
+		name := fmt.Sprintf("%s-%s.vgm", e.GBC.Cartridge.Title, time.Now().Format("20060102-150405"))
+		path = filepath.Join(e.RomDir, name)
+	}
+
+	v, err := audio.NewVGM(path, e.GBC.Sound.Cycles(), e.GBC.Sound.InitWrites())
+	if err != nil {
+		return err
+	}
+	e.vgm = v
+	return nil
+}
+
+// StopVGM stops VGM logging and returns VGM file name
+func (e *Emulator) StopVGM() (string, error) {
+	if e.vgm == nil {
+		return "", nil
+	}
+
+	name := e.vgm.Name()
+	err := e.vgm.Close(e.GBC.Sound.Cycles())
+	e.vgm = nil
+	return name, err
+}
+
+// StartMIDI starts exporting sound channels into Standard MIDI File, if path is empty, it's saved in ROM directory.
+//
+// This must be called on emulator goroutine(e.g. by runTask).
+func (e *Emulator) StartMIDI(path string) error {
+	e.StopMIDI()
+	if path == "" {
+		name := fmt.Sprintf("%s-%s.mid", e.GBC.Cartridge.Title, time.Now().Format("20060102-150405"))
+		path = filepath.Join(e.RomDir, name)
+	}
+
+	m, err := audio.NewMIDI(path, e.GBC.Sound.Cycles())
+	if err != nil {
+		return err
+	}
+	e.midi = m
+	e.pollMIDI()
+	return nil
+}
+
+// StopMIDI stops MIDI export and returns MIDI file name
+func (e *Emulator) StopMIDI() (string, error) {
+	if e.midi == nil {
+		return "", nil
+	}
+
+	name := e.midi.Name()
+	err := e.midi.Close(e.GBC.Sound.Cycles())
+	e.midi = nil
+	return name, err
+}
+
+// onSoundWrite is apu.APU.OnWrite, it passes register writes to VGM and MIDI loggers
+func (e *Emulator) onSoundWrite(cycles uint64, offset, value byte) {
+	if e.vgm != nil {
+		e.vgm.Write(cycles, offset, value)
+	}
+	if e.midi != nil {
+		e.midi.Write(cycles, offset, value, e.GBC.Sound.State())
+	}
+}
+
+// pollMIDI passes sound state changes without register writes(e.g. length counter) to MIDI, this is called every frame
+func (e *Emulator) pollMIDI() {
+	if e.midi != nil {
+		e.midi.Poll(e.GBC.Sound.Cycles(), e.GBC.Sound.State())
+	}
+}
+
+func (e *Emulator) startRecording(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	stems := q.Get("stems") == "true" || q.Get("stems") == "1"
+
+	var files []string
+	var err error
+	e.runTask(func() {
+		if err = e.StartRecording(q.Get("file"), stems); err == nil {
+			files = e.recorder.Files()
+		}
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(strings.Join(files, "\n")))
+}
+
+func (e *Emulator) stopRecording(w http.ResponseWriter, req *http.Request) {
+	var files []string
+	var err error
+	e.runTask(func() { files, err = e.StopRecording() })
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(strings.Join(files, "\n")))
+}
+
+// audioState returns each sound channel's live state
+func (e *Emulator) audioState(w http.ResponseWriter, req *http.Request) {
+	var s apu.State
+	e.runTask(func() { s = e.GBC.Sound.State() })
+	writeAudioState(w, s)
+}
+
+func writeAudioState(w http.ResponseWriter, s apu.State) {
+	res, err := json.Marshal(s)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+// parseChannel parses `ch` query, min is 0 if `ch=0` is allowed
+func parseChannel(req *http.Request, min int) (int, error) {
+	ch, err := strconv.Atoi(req.URL.Query().Get("ch"))
+	if err != nil || ch < min || ch > 4 {
+		return 0, fmt.Errorf("`ch` must be %d..4(e.g. ?ch=1)", min)
+	}
+	return ch, nil
+}
+
+// muteChannel toggles channel mute, `on=true|false` sets it explicitly
+func (e *Emulator) muteChannel(w http.ResponseWriter, req *http.Request) {
+	ch, err := parseChannel(req, 1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	on := req.URL.Query().Get("on")
+	if on != "" && on != "true" && on != "false" {
+		http.Error(w, "`on` must be true or false", http.StatusBadRequest)
+		return
+	}
+
+	var s apu.State
+	e.runTask(func() {
+		if on == "" {
+			e.GBC.Sound.ToggleSoundChannel(ch)
+		} else {
+			e.GBC.Sound.SetMute(ch, on == "true")
+		}
+		s = e.GBC.Sound.State()
+	})
+	writeAudioState(w, s)
+}
+
+// soloChannel toggles channel solo, `ch=0` clears solo
+func (e *Emulator) soloChannel(w http.ResponseWriter, req *http.Request) {
+	ch, err := parseChannel(req, 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var s apu.State
+	e.runTask(func() {
+		e.toggleSolo(ch)
+		s = e.GBC.Sound.State()
+	})
+	writeAudioState(w, s)
+}
+
+func (e *Emulator) toggleSolo(ch int) {
+	if e.GBC.Sound.Solo() == ch {
+		ch = 0
+	}
+	e.GBC.Sound.SetSolo(ch)
+	log.Printf("Solo Channel %v\n", ch)
+}
+
+// gainChannel sets channel output gain(0..4), 1 is hardware volume
+func (e *Emulator) gainChannel(w http.ResponseWriter, req *http.Request) {
+	ch, err := parseChannel(req, 1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	gain, err := strconv.ParseFloat(req.URL.Query().Get("v"), 64)
+	if err != nil || gain < 0 || gain > 4 {
+		http.Error(w, "`v` must be 0..4(e.g. ?ch=1&v=0.5)", http.StatusBadRequest)
+		return
+	}
+	var s apu.State
+	e.runTask(func() {
+		e.GBC.Sound.SetGain(ch, gain)
+		s = e.GBC.Sound.State()
+	})
+	writeAudioState(w, s)
+}
+
+func (e *Emulator) startVGM(w http.ResponseWriter, req *http.Request) {
+	var name string
+	var err error
+	e.runTask(func() {
+		if err = e.StartVGM(req.URL.Query().Get("file")); err == nil {
+			name = e.vgm.Name()
+		}
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(name))
+}
+
+// loopVGM sets VGM loop point at current time
+func (e *Emulator) loopVGM(w http.ResponseWriter, req *http.Request) {
+	started := false
+	e.runTask(func() {
+		if e.vgm != nil {
+			e.vgm.Loop(e.GBC.Sound.Cycles())
+			started = true
+		}
+	})
+	if !started {
+		http.Error(w, "VGM logging is not started", http.StatusBadRequest)
+	}
+}
+
+func (e *Emulator) stopVGM(w http.ResponseWriter, req *http.Request) {
+	var name string
+	var err error
+	e.runTask(func() { name, err = e.StopVGM() })
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(name))
+}
+
+func (e *Emulator) startMIDI(w http.ResponseWriter, req *http.Request) {
+	var name string
+	var err error
+	e.runTask(func() {
+		if err = e.StartMIDI(req.URL.Query().Get("file")); err == nil {
+			name = e.midi.Name()
+		}
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(name))
+}
+
+func (e *Emulator) stopMIDI(w http.ResponseWriter, req *http.Request) {
+	var name string
+	var err error
+	e.runTask(func() { name, err = e.StopMIDI() })
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(name))
+}
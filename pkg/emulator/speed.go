@@ -0,0 +1,145 @@
+package emulator
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pokemium/worldwide/pkg/emulator/joypad"
+)
+
+const (
+	SpeedUnlimited = 0 // run frames as many as possible
+	minSpeed       = 0.25
+	maxFrameskip   = 15
+)
+
+// frames to run in this tick(1/60 sec)
+func (e *Emulator) framesToRun(speed float64) int {
+	if speed == SpeedUnlimited {
+		return maxFrameskip + 1
+	}
+
+	e.frameDebt += speed
+	frames := int(e.frameDebt)
+	e.frameDebt -= float64(frames)
+	return frames
+}
+
+// runFrames runs n frames, only the last frame is rendered.
+// It stops if the emulator is paused on the way by debugger or CPU lockup.
+func (e *Emulator) runFrames(n int, speed float64) error {
+	if n == 0 {
+		return nil
+	}
+
+	skip := n - 1
+	if skip > maxFrameskip {
+		skip = maxFrameskip
+	}
+	e.GBC.Video.SetFrameskip(skip)
+
+	deadline := time.Now().Add(time.Second / 60)
+	for i := 0; i < n; i++ {
+		if err := e.GBC.Update(); err != nil {
+			return err
+		}
+		joypad.Tick()
+		e.pollMIDI()
+		e.checkLockup()
+		if e.pause {
+			return nil
+		}
+		if speed == SpeedUnlimited && time.Now().After(deadline) {
+			break
+		}
+	}
+	return nil
+}
+
+func (e *Emulator) currentSpeed() float64 {
+	if joypad.HotkeyHeld(joypad.HotkeyFastForward) {
+		return e.fastForward
+	}
+	return e.speed
+}
+
+// advanceFrame runs a frame on pause state
+func (e *Emulator) advanceFrame() error {
+	e.advance--
+	e.pause = false
+	err := e.runFrames(1, 1)
+	e.pause = true
+	cache = e.GBC.Draw()
+	return err
+}
+
+func parseSpeed(s string) (float64, error) {
+	if s == "unlimited" {
+		return SpeedUnlimited, nil
+	}
+	speed, err := strconv.ParseFloat(s, 64)
+	if err != nil || (speed != SpeedUnlimited && speed < minSpeed) {
+		return 0, fmt.Errorf("speed must be 'unlimited', 0 or greater than or equal to %.2f", minSpeed)
+	}
+	return speed, nil
+}
+
+// Speed gets or sets emulation speed
+//
+// x is normal speed multiplier, ff is fast-forward speed multiplier used while fast-forward hotkey is held.
+func (e *Emulator) Speed(w http.ResponseWriter, req *http.Request) {
+	// parse both first not to apply only one of them on error
+	q := req.URL.Query()
+	var speeds [2]*float64 // x, ff
+	for i, key := range []string{"x", "ff"} {
+		if s := q.Get(key); s != "" {
+			speed, err := parseSpeed(s)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			speeds[i] = &speed
+		}
+	}
+
+	var x, ff float64
+	e.runTask(func() {
+		if speeds[0] != nil {
+			e.speed = *speeds[0]
+		}
+		if speeds[1] != nil {
+			e.fastForward = *speeds[1]
+		}
+		x, ff = e.speed, e.fastForward
+	})
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(fmt.Sprintf("x=%s ff=%s", speedString(x), speedString(ff))))
+}
+
+// FrameAdvance pauses emulator and runs n frames
+func (e *Emulator) FrameAdvance(w http.ResponseWriter, req *http.Request) {
+	n := 1
+	if s := req.URL.Query().Get("n"); s != "" {
+		var err error
+		n, err = strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			http.Error(w, "`n` must be positive number(e.g. ?n=10)", http.StatusBadRequest)
+			return
+		}
+	}
+
+	e.runTask(func() {
+		e.pause = true
+		e.advance += n
+	})
+}
+
+func speedString(speed float64) string {
+	if speed == SpeedUnlimited {
+		return "unlimited"
+	}
+	return strconv.FormatFloat(speed, 'f', -1, 64)
+}
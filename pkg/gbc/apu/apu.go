@@ -1,24 +1,21 @@
 package apu
 
-// copy and hack source code from goboy
-
 import (
-	"fmt"
 	"log"
 	"math"
+	"sync/atomic"
 
 	"github.com/pokemium/worldwide/pkg/util"
 )
 
 const (
-	SAMPLE_RATE = 44100
-	twoPi       = 2 * math.Pi
-	perSample   = 1 / float64(SAMPLE_RATE)
-
-	cpuTicksPerSample = float64(4194304) / SAMPLE_RATE
-	STREAM_LEN        = 2940 // 2 * 2 * SAMPLE_RATE * (1/60)
-	VOLUME            = 0.07
-	BUF_SEC           = 60
+	SAMPLE_RATE = 44100   // default host sample rate
+	CLOCK       = 4194304 // APU runs at 4MHz in both speed modes
+	BUF_SEC     = 60
+
+	// high-pass filter charge factor per clock
+	dmgCharge = 0.999958
+	cgbCharge = 0.998943
 )
 
 // APU is the GameBoy's audio processing unit. Audio comprises four
@@ -26,317 +23,487 @@ const (
 //
 // Channels 1 and 2 are both Square channels, channel 3 is a arbitrary
 // waveform channel which can be set in RAM, and channel 4 outputs noise.
+//
+// ref: https://gbdev.gg8.se/wiki/articles/Gameboy_sound_hardware
 type APU struct {
 	Enable bool
+	CGB    bool // CGB APU doesn't keep length counters on power off and wave RAM is always accessible
+
+	power bool
+	regs  [0x20]byte // 0xff10..0xff2f
 
-	memory      [52]byte
-	waveformRAM []byte
+	ch1, ch2 square
+	ch3      wave
+	ch4      noise
 
-	chn1, chn2, chn3, chn4 *Channel
-	tickCounter            float64
-	lVol, rVol             float64
+	frameStep int // next step of 512Hz frame sequencer
+	cycles    uint64
 
-	audioBuffer    chan [2]byte
-	setAudioStream func([]byte)
+	mixer [4]mixerChannel // debug mute, gain
+	solo  int             // soloed channel(1..4), 0 means no solo
+
+	// output is signed 16bit interleaved stereo at sampleRate
+	sampleRate     int
+	ratio          float64
+	mix            *output   // playback, resampled by ratio
+	recMix         *output   // recording, resampled at fixed ratio, nil unless recording
+	stems          []*output // each channel output for recording, nil unless enabled
+	frameStart     uint64
+	setAudioStream func([]int16)
+	scope          *scope       // nil unless debugger shows waveforms, only emulator goroutine touches this
+	scopeOn        int32        // requested by EnableScope, applied on frame end
+	scopeView      atomic.Value // *scope for Scope
+
+	record func(mix []int16, stems [4][]int16) // set by SetRecord
+
+	// OnWrite is called after every register and wave RAM write with APU clocks
+	OnWrite func(cycles uint64, offset, value byte)
 }
 
 // Init the sound emulation for a Gameboy.
-func New(enable bool, setAudioStream func([]byte)) *APU {
+func New(enable bool, setAudioStream func([]int16)) *APU {
 	a := &APU{
 		Enable:         enable,
 		setAudioStream: setAudioStream,
 	}
-	a.waveformRAM = make([]byte, 0x20)
-	a.audioBuffer = make(chan [2]byte, STREAM_LEN)
+	a.SetSampleRate(SAMPLE_RATE)
+	a.ch1.lengthMax, a.ch2.lengthMax, a.ch3.lengthMax, a.ch4.lengthMax = 64, 64, 256, 64
+	for i := range a.mixer {
+		a.mixer[i].gain = 1
+	}
 
 	// Sets waveform ram to:
 	// 00 FF 00 FF  00 FF 00 FF  00 FF 00 FF  00 FF 00 FF
-	for x := 0x0; x < 0x20; x++ {
-		if x&2 == 0 {
-			a.waveformRAM[x] = 0x00
-		} else {
-			a.waveformRAM[x] = 0xFF
+	for x := 0; x < 0x10; x++ {
+		if x&1 == 1 {
+			a.ch3.ram[x] = 0xff
 		}
 	}
 
-	// Create the channels with their sounds
-	a.chn1 = NewChannel()
-	a.chn2 = NewChannel()
-	a.chn3 = NewChannel()
-	a.chn4 = NewChannel()
-
 	return a
 }
 
-// Plays the sound
+// SetSampleRate sets host sample rate
+func (a *APU) SetSampleRate(rate int) {
+	a.sampleRate, a.ratio = rate, 1
+	a.mix = newOutput(rate)
+	if a.record != nil {
+		a.SetRecord(a.record, a.stems != nil)
+	}
+	a.frameStart = a.cycles
+}
+
+func (a *APU) SampleRate() int { return a.sampleRate }
+
+// SetRatio adjusts playback resampling ratio for dynamic rate control, ratio > 1 generates more samples
 //
-// This function is called 60 times per second.
-func (a *APU) Update() {
-	if !a.Enable {
+// Recording output isn't affected, so it always has exact sample rate.
+func (a *APU) SetRatio(ratio float64) {
+	a.ratio = ratio
+	a.mix.setFactor(float64(a.sampleRate) * ratio / CLOCK)
+}
+
+// SetRecord passes mixed output and each channel stem(if stems is true) to f every frame, even if sound is muted
+//
+// f = nil stops recording. Samples are reused on next frame, so copy them to keep.
+func (a *APU) SetRecord(f func(mix []int16, stems [4][]int16), stems bool) {
+	a.record, a.recMix, a.stems = f, nil, nil
+	if f == nil {
 		return
 	}
 
-	targetSamples := SAMPLE_RATE / BUF_SEC
-	var reading [2]byte
-	var buffer []byte
-	fbLen := len(a.audioBuffer)
-	if fbLen >= targetSamples/2 {
-		newBuffer := make([]byte, fbLen*2)
-		for i := 0; i < fbLen*2; i += 2 {
-			reading = <-a.audioBuffer
-			newBuffer[i], newBuffer[i+1] = reading[0], reading[1]
+	a.recMix = newOutput(a.sampleRate)
+	if stems {
+		a.stems = make([]*output, 4)
+		for i := range a.stems {
+			a.stems[i] = newOutput(a.sampleRate)
 		}
-		buffer = newBuffer
 	}
-	a.setAudioStream(buffer)
 }
 
-func (a *APU) Buffer(cpuTicks int) {
-	if !a.Enable {
+// Plays the sound
+//
+// This function is called 60 times per second.
+func (a *APU) Update() {
+	clocks := a.cycles - a.frameStart
+	a.frameStart = a.cycles
+	a.updateScope()
+	if !a.Enable && a.record == nil && a.scope == nil {
 		return
 	}
-	a.tickCounter += float64(cpuTicks)
-	if a.tickCounter < cpuTicksPerSample {
-		return
+
+	charge := math.Pow(dmgCharge, float64(CLOCK)/float64(a.sampleRate))
+	if a.CGB {
+		charge = math.Pow(cgbCharge, float64(CLOCK)/float64(a.sampleRate))
+	}
+
+	samples := a.mix.read(clocks, charge)
+	if a.Enable {
+		a.setAudioStream(samples)
 	}
-	a.tickCounter -= cpuTicksPerSample
 
-	chn1l, chn1r := a.chn1.Sample()
-	chn2l, chn2r := a.chn2.Sample()
-	chn3l, chn3r := a.chn3.Sample()
-	chn4l, chn4r := a.chn4.Sample()
+	if a.record != nil {
+		var stems [4][]int16
+		for i, o := range a.stems {
+			stems[i] = o.read(clocks, charge)
+		}
+		a.record(a.recMix.read(clocks, charge), stems)
+	}
+}
+
+// Tick advances APU by dots
+//
+// channels are stepped until their next timer event so that blip gets every amplitude change at exact clock.
+func (a *APU) Tick(dots uint32) {
+	for remaining := int(dots); remaining > 0; {
+		step := remaining
+		if a.power {
+			for _, timer := range [4]int{a.ch1.timer, a.ch2.timer, a.ch3.timer, a.ch4.timer} {
+				if timer < step {
+					step = timer
+				}
+			}
+			if step < 1 {
+				step = 1
+			}
+		}
 
-	valL := (chn1l + chn2l + chn3l + chn4l) / 4
-	valR := (chn1r + chn2r + chn3r + chn4r) / 4
+		if a.scope != nil {
+			a.scope.capture(a, step)
+		}
 
-	lVol, rVol := valL*a.lVol*VOLUME, valR*a.rVol*VOLUME
-	a.audioBuffer <- [2]byte{byte(lVol), byte(rVol)}
+		a.cycles += uint64(step)
+		if a.power {
+			a.ch1.tick(step)
+			a.ch2.tick(step)
+			a.ch3.tick(step, a.cycles)
+			a.ch4.tick(step)
+		}
+		if a.Enable || a.record != nil || a.scope != nil {
+			a.updateLevel()
+		}
+		remaining -= step
+	}
 }
 
-var soundMask = []byte{
-	/* 0xFF10 */ 0xFF, 0xC0, 0xFF, 0x00, 0x40,
-	/* 0xFF15 */ 0x00, 0xC0, 0xFF, 0x00, 0x40,
-	/* 0xFF1A */ 0x80, 0x00, 0x60, 0x00, 0x40,
-	/* 0xFF20 */ 0x00, 0x3F, 0xFF, 0xFF, 0x40,
-	/* 0xFF24 */ 0xFF, 0xFF, 0x80,
+// FrameSequencer steps 512Hz frame sequencer, it's clocked by falling edge of DIV bit 4 (bit 5 on double speed)
+//
+// Step   Length Ctr  Vol Env     Sweep
+// ---------------------------------------
+// 0      Clock       -           -
+// 1      -           -           -
+// 2      Clock       -           Clock
+// 3      -           -           -
+// 4      Clock       -           -
+// 5      -           -           -
+// 6      Clock       -           Clock
+// 7      -           Clock       -
+func (a *APU) FrameSequencer() {
+	if !a.power {
+		return
+	}
+
+	step := a.frameStep
+	a.frameStep = (a.frameStep + 1) & 7
+	if step&1 == 0 {
+		a.ch1.clockLength()
+		a.ch2.clockLength()
+		a.ch3.clockLength()
+		a.ch4.clockLength()
+	}
+	if step == 2 || step == 6 {
+		a.ch1.clockSweep()
+	}
+	if step == 7 {
+		a.ch1.env.clock()
+		a.ch2.env.clock()
+		a.ch4.env.clock()
+	}
 }
 
-var channel3Volume = map[byte]float64{0: 0, 1: 1, 2: 0.5, 3: 0.25}
+// updateLevel passes output changes to blip
+//
+// debug mute, solo and gain are applied only to playback, recording gets hardware output.
+func (a *APU) updateLevel() {
+	nr50, nr51 := a.regs[0x24-0x10], a.regs[0x25-0x10]
+	leftVol, rightVol := float64((nr50>>4)&7+1)/8, float64(nr50&7+1)/8
+
+	var left, right, recLeft, recRight float64
+	now := a.cycles - a.frameStart
+	for i, out := range [4]float64{a.ch1.output(), a.ch2.output(), a.ch3.output(), a.ch4.output()} {
+		l, r := 0.0, 0.0
+		if util.Bit(nr51, i+4) {
+			l = out * leftVol
+		}
+		if util.Bit(nr51, i) {
+			r = out * rightVol
+		}
+		if a.stems != nil {
+			a.stems[i].set(now, l, r)
+		}
+		recLeft, recRight = recLeft+l, recRight+r
+
+		gain := a.channelGain(i)
+		left, right = left+l*gain, right+r*gain
+	}
+	a.mix.set(now, left, right)
+	if a.recMix != nil {
+		a.recMix.set(now, recLeft, recRight)
+	}
+}
 
-var squareLimits = map[byte]float64{
-	0: -0.25, // 12.5% ( _-------_-------_------- )
-	1: -0.5,  // 25%   ( __------__------__------ )
-	2: 0,     // 50%   ( ____----____----____---- ) (normal)
-	3: 0.5,   // 75%   ( ______--______--______-- )
+// readMask is OR-ed on reading registers (unused bits and write-only registers read as 1)
+var readMask = [0x20]byte{
+	/* 0xFF10 */ 0x80, 0x3F, 0x00, 0xFF, 0xBF,
+	/* 0xFF15 */ 0xFF, 0x3F, 0x00, 0xFF, 0xBF,
+	/* 0xFF1A */ 0x7F, 0xFF, 0x9F, 0xFF, 0xBF,
+	/* 0xFF1F */ 0xFF, 0xFF, 0x00, 0x00, 0xBF,
+	/* 0xFF24 */ 0x00, 0x00, 0x70,
+	/* 0xFF27 */ 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
 }
 
 // Read returns a value from the APU.
 func (a *APU) Read(offset byte) byte {
 	if offset >= 0x30 {
-		return a.waveformRAM[offset-0x30]
+		idx, ok := a.waveIndex(offset)
+		if !ok {
+			return 0xff
+		}
+		return a.ch3.ram[idx]
 	}
-	// TODO: we should modify the sound memory as we're sampling
-	return a.memory[offset-0x00] & soundMask[offset-0x10]
+
+	if offset == 0x26 {
+		value := readMask[0x26-0x10]
+		for i, enabled := range [4]bool{a.ch1.enabled, a.ch2.enabled, a.ch3.enabled, a.ch4.enabled} {
+			value = util.SetBit8(value, i, enabled)
+		}
+		return util.SetBit8(value, 7, a.power)
+	}
+	return a.regs[offset-0x10] | readMask[offset-0x10]
+}
+
+// PCM12 returns digital output of channel 1(low nibble) and channel 2(high nibble), CGB only
+func (a *APU) PCM12() byte { return byte(a.ch1.digital() | a.ch2.digital()<<4) }
+
+// PCM34 returns digital output of channel 3(low nibble) and channel 4(high nibble), CGB only
+func (a *APU) PCM34() byte { return byte(a.ch3.digital() | a.ch4.digital()<<4) }
+
+// waveIndex returns wave RAM index CPU accesses, while channel 3 is playing CPU accesses the byte channel 3 is reading
+func (a *APU) waveIndex(offset byte) (int, bool) {
+	if !a.ch3.enabled {
+		return int(offset - 0x30), true
+	}
+	// DMG can access only at the moment channel 3 reads wave RAM
+	if a.CGB || a.cycles-a.ch3.readAt < 2 {
+		return a.ch3.pos >> 1, true
+	}
+	return 0, false
 }
 
 // Write a value to the APU registers.
 func (a *APU) Write(offset byte, value byte) {
-	a.memory[offset] = value
+	a.write(offset, value)
+	if a.OnWrite != nil {
+		a.OnWrite(a.cycles, offset, value)
+	}
+}
 
-	switch uint16(offset) + 0xff00 {
-	// Channel 1
-	case 0xFF10:
-		// -PPP NSSS Sweep period, negate, shift
-		a.chn1.sweepStepLen = (a.memory[0x10] & 0b111_0000) >> 4
-		a.chn1.sweepSteps = a.memory[0x10] & 0b111
-		a.chn1.sweepIncrease = !util.Bit(a.memory[0x10], 3) // 1 = decrease
-	case 0xFF11:
-		// DDLL LLLL Duty, Length load
-		duty := (value & 0b1100_0000) >> 6
-		a.chn1.generator = Square(squareLimits[duty])
-		a.chn1.length = int(value & 0b0011_1111)
-	case 0xFF12:
-		// VVVV APPP - Starting volume, Envelop add mode, period
-		envVolume, envDirection, envSweep := a.extractEnvelope(value)
-		a.chn1.envelopeVolume = int(envVolume)
-		a.chn1.envelopeSamples = int(envSweep) * SAMPLE_RATE / 64
-		a.chn1.envelopeIncreasing = envDirection == 1
-	case 0xFF13:
-		// FFFF FFFF Frequency LSB
-		frequencyValue := uint16(a.memory[0x14]&0b111)<<8 | uint16(value)
-		a.chn1.frequency = 131072 / (2048 - float64(frequencyValue))
-	case 0xFF14:
-		// TL-- -FFF Trigger, Length Enable, Frequencu MSB
-		frequencyValue := uint16(value&0b111)<<8 | uint16(a.memory[0x13])
-		a.chn1.frequency = 131072 / (2048 - float64(frequencyValue))
-		if util.Bit(value, 7) {
-			if a.chn1.length == 0 {
-				a.chn1.length = 64
-			}
-			duration := -1
-			if util.Bit(value, 6) { // 1 = use length
-				duration = int(float64(a.chn1.length)*(1/64)) * SAMPLE_RATE
-			}
-			a.chn1.Reset(duration)
-			a.chn1.envelopeSteps = a.chn1.envelopeVolume
-			a.chn1.envelopeStepsInit = a.chn1.envelopeVolume
-			// TODO: Square 1's sweep does several things (see frequency sweep).
+func (a *APU) write(offset byte, value byte) {
+	if offset >= 0x30 {
+		if idx, ok := a.waveIndex(offset); ok {
+			a.ch3.ram[idx] = value
 		}
+		return
+	}
 
-	// Channel 2
-	case 0xFF15:
-		// ---- ---- Not used
-	case 0xFF16:
+	if !a.power && offset != 0x26 {
+		// while powered off, registers are read-only except length counters on DMG
+		if a.CGB {
+			return
+		}
+		switch offset {
+		case 0x11, 0x16, 0x20:
+			value &= 0x3f
+		case 0x1b:
+		default:
+			return
+		}
+	}
+	a.regs[offset-0x10] = value
+
+	switch offset {
+	// Channel 1
+	case 0x10:
+		a.ch1.writeSweep(value)
+	case 0x11:
 		// DDLL LLLL Duty, Length load (64-L)
-		pattern := (value & 0b1100_0000) >> 6
-		a.chn2.generator = Square(squareLimits[pattern])
-		a.chn2.length = int(value & 0b11_1111)
-	case 0xFF17:
-		// VVVV APPP Starting volume, Envelope add mode, period
-		envVolume, envDirection, envSweep := a.extractEnvelope(value)
-		a.chn2.envelopeVolume = int(envVolume)
-		a.chn2.envelopeSamples = int(envSweep) * SAMPLE_RATE / 64
-		a.chn2.envelopeIncreasing = envDirection == 1
-	case 0xFF18:
+		a.ch1.duty = int(value >> 6)
+		a.ch1.length = 64 - int(value&0x3f)
+	case 0x12:
+		a.ch1.env.write(value)
+		a.ch1.setDAC(value&0xf8 != 0)
+	case 0x13:
 		// FFFF FFFF Frequency LSB
-		frequencyValue := uint16(a.memory[0x19]&0b111)<<8 | uint16(value)
-		a.chn2.frequency = 131072 / (2048 - float64(frequencyValue))
-	case 0xFF19:
+		a.ch1.freq = (a.ch1.freq & 0x700) | int(value)
+	case 0x14:
 		// TL-- -FFF Trigger, Length enable, Frequency MSB
-		if util.Bit(value, 7) {
-			if a.chn2.length == 0 {
-				a.chn2.length = 64
-			}
-			duration := -1
-			if util.Bit(value, 6) {
-				duration = int(float64(a.chn2.length)*(1/64)) * SAMPLE_RATE
-			}
-			a.chn2.Reset(duration)
-			a.chn2.envelopeSteps = a.chn2.envelopeVolume
-			a.chn2.envelopeStepsInit = a.chn2.envelopeVolume
+		a.ch1.freq = (a.ch1.freq & 0xff) | int(value&7)<<8
+		if a.writeControl(&a.ch1.channel, value) {
+			a.ch1.trigger()
+			a.ch1.triggerSweep()
+		}
+
+	// Channel 2
+	case 0x16:
+		a.ch2.duty = int(value >> 6)
+		a.ch2.length = 64 - int(value&0x3f)
+	case 0x17:
+		a.ch2.env.write(value)
+		a.ch2.setDAC(value&0xf8 != 0)
+	case 0x18:
+		a.ch2.freq = (a.ch2.freq & 0x700) | int(value)
+	case 0x19:
+		a.ch2.freq = (a.ch2.freq & 0xff) | int(value&7)<<8
+		if a.writeControl(&a.ch2.channel, value) {
+			a.ch2.trigger()
 		}
-		frequencyValue := uint16(value&0b111)<<8 | uint16(a.memory[0x18])
-		a.chn2.frequency = 131072 / (2048 - float64(frequencyValue))
 
 	// Channel 3
-	case 0xFF1A:
+	case 0x1a:
 		// E--- ---- DAC power
-		a.chn3.envelopeStepsInit = int((value & 0b1000_0000) >> 7)
-	case 0xFF1B:
-		// LLLL LLLL Length load
-		a.chn3.length = int(value)
-	case 0xFF1C:
+		a.ch3.setDAC(util.Bit(value, 7))
+	case 0x1b:
+		// LLLL LLLL Length load (256-L)
+		a.ch3.length = 256 - int(value)
+	case 0x1c:
 		// -VV- ---- Volume code
-		selection := (value & 0b110_0000) >> 5
-		a.chn3.amplitude = channel3Volume[selection]
-	case 0xFF1D:
-		// FFFF FFFF Frequency LSB
-		frequencyValue := uint16(a.memory[0x1E]&0b111)<<8 | uint16(value)
-		a.chn3.frequency = 65536 / (2048 - float64(frequencyValue))
-	case 0xFF1E:
-		// TL-- -FFF Trigger, Length enable, Frequency MSB
-		if util.Bit(value, 7) {
-			if a.chn3.length == 0 {
-				a.chn3.length = 256
-			}
-			duration := -1
-			if value&0b100_0000 != 0 { // 1 = use length
-				duration = int((256-float64(a.chn3.length))*(1/256)) * SAMPLE_RATE
-			}
-			a.chn3.generator = Waveform(func(i int) byte { return a.waveformRAM[i] })
-			a.chn3.duration = duration
+		a.ch3.volume = int(value>>5) & 3
+	case 0x1d:
+		a.ch3.freq = (a.ch3.freq & 0x700) | int(value)
+	case 0x1e:
+		a.ch3.freq = (a.ch3.freq & 0xff) | int(value&7)<<8
+		if !a.CGB {
+			a.ch3.corrupt()
+		}
+		if a.writeControl(&a.ch3.channel, value) {
+			a.ch3.trigger()
 		}
-		frequencyValue := uint16(value&0b111)<<8 | uint16(a.memory[0x1D])
-		a.chn3.frequency = 65536 / (2048 - float64(frequencyValue))
 
 	// Channel 4
-	case 0xFF1F:
-		// ---- ---- Not used
-	case 0xFF20:
-		// --LL LLLL Length load
-		a.chn4.length = int(value & 0b11_1111)
-	case 0xFF21:
-		// VVVV APPP Starting volume, Envelope add mode, period
-		envVolume, envDirection, envSweep := a.extractEnvelope(value)
-		a.chn4.envelopeVolume = int(envVolume)
-		a.chn4.envelopeSamples = int(envSweep) * SAMPLE_RATE / 64
-		a.chn4.envelopeIncreasing = envDirection == 1
-	case 0xFF22:
+	case 0x20:
+		// --LL LLLL Length load (64-L)
+		a.ch4.length = 64 - int(value&0x3f)
+	case 0x21:
+		a.ch4.env.write(value)
+		a.ch4.setDAC(value&0xf8 != 0)
+	case 0x22:
 		// SSSS WDDD Clock shift, Width mode of LFSR, Divisor code
-		shiftClock := float64((value & 0b1111_0000) >> 4)
-		// TODO: counter step width
-		divRatio := float64(value & 0b111)
-		if divRatio == 0 {
-			divRatio = 0.5
-		}
-		a.chn4.frequency = 524288 / divRatio / math.Pow(2, shiftClock+1)
-	case 0xFF23:
+		a.ch4.shift = int(value >> 4)
+		a.ch4.width7 = util.Bit(value, 3)
+		a.ch4.divisor = int(value & 7)
+	case 0x23:
 		// TL-- ---- Trigger, Length enable
-		if util.Bit(value, 7) {
-			duration := -1
-			if util.Bit(value, 6) { // 1 = use length
-				duration = int(float64(61-a.chn4.length)*(1/256)) * SAMPLE_RATE
-			}
-			a.chn4.generator = Noise()
-			a.chn4.Reset(duration)
-			a.chn4.envelopeSteps = a.chn4.envelopeVolume
-			a.chn4.envelopeStepsInit = a.chn4.envelopeVolume
+		if a.writeControl(&a.ch4.channel, value) {
+			a.ch4.trigger()
 		}
 
-	case 0xFF24:
-		// Volume control
-		a.lVol = float64((a.memory[0x24]&0x70)>>4) / 7
-		a.rVol = float64(a.memory[0x24]&0x7) / 7
-
-	case 0xFF25:
-		// Channel control
-		a.chn1.onR = value&0x1 != 0
-		a.chn2.onR = value&0x2 != 0
-		a.chn3.onR = value&0x4 != 0
-		a.chn4.onR = value&0x8 != 0
-		a.chn1.onL = value&0x10 != 0
-		a.chn2.onL = value&0x20 != 0
-		a.chn3.onL = value&0x40 != 0
-		a.chn4.onL = value&0x80 != 0
-	}
-	// TODO: if writing to FF26 bit 7 destroy all contents (also cannot access)
+	case 0x26:
+		a.setPower(util.Bit(value, 7))
+	}
 }
 
-// WriteWaveform writes a value to the waveform ram.
-func (a *APU) WriteWaveform(offset byte, value byte) {
-	soundIndex := (offset - 0x30) * 2
-	a.waveformRAM[soundIndex] = (value >> 4) & 0xF * 0x11
-	a.waveformRAM[soundIndex+1] = value & 0xF * 0x11
+// writeControl handles NRx4 length enable and returns whether the channel is triggered
+func (a *APU) writeControl(c *channel, value byte) bool {
+	// length is clocked when it's enabled in the first half of length period (next frame sequencer step doesn't clock length)
+	firstHalf := a.frameStep&1 == 1
+	trigger := util.Bit(value, 7)
+
+	wasEnabled := c.lengthEnable
+	c.lengthEnable = util.Bit(value, 6)
+	if firstHalf && !wasEnabled && c.lengthEnable && c.length > 0 {
+		c.length--
+		if c.length == 0 && !trigger {
+			c.enabled = false
+		}
+	}
+
+	if !trigger {
+		return false
+	}
+	c.enabled = c.dac
+	if c.length == 0 {
+		c.length = c.lengthMax
+		if c.lengthEnable && firstHalf {
+			c.length--
+		}
+	}
+	return true
 }
 
-// ToggleSoundChannel toggles a sound channel for debugging.
-func (a *APU) ToggleSoundChannel(channel int) {
-	switch channel {
-	case 1:
-		a.chn1.debugOff = !a.chn1.debugOff
-	case 2:
-		a.chn2.debugOff = !a.chn2.debugOff
-	case 3:
-		a.chn3.debugOff = !a.chn3.debugOff
-	case 4:
-		a.chn4.debugOff = !a.chn4.debugOff
+// setDAC turns channel DAC on/off, turning off DAC disables channel
+func (c *channel) setDAC(on bool) {
+	c.dac = on
+	if !on {
+		c.enabled = false
 	}
-	log.Printf("Toggle Channel %v mute", channel)
 }
 
-func (a *APU) LogSoundState() {
-	fmt.Println("Channel 3")
-	fmt.Printf("  0xFF1A E--- ---- = %08b\n", a.memory[0x1A])
-	fmt.Printf("  0xFF1B LLLL LLLL = %08b\n", a.memory[0x1B])
-	fmt.Printf("  0xFF1C -VV- ---- = %08b\n", a.memory[0x1C])
-	fmt.Printf("  0xFF1D FFFF FFFF = %08b\n", a.memory[0x1D])
-	fmt.Printf("  0xFF1E TL-- -FFF = %08b\n", a.memory[0x1E])
+// NR52 bit7
+func (a *APU) setPower(on bool) {
+	if a.power == on {
+		return
+	}
+
+	if !on {
+		// all registers are cleared, DMG keeps length counters
+		lengths := [4]int{a.ch1.length, a.ch2.length, a.ch3.length, a.ch4.length}
+		for offset := byte(0x10); offset <= 0x25; offset++ {
+			a.write(offset, 0)
+		}
+		if !a.CGB {
+			a.ch1.length, a.ch2.length, a.ch3.length, a.ch4.length = lengths[0], lengths[1], lengths[2], lengths[3]
+		}
+		a.power = false
+		return
+	}
+
+	a.power = true
+	a.frameStep = 0
+	a.ch1.dutyPos, a.ch2.dutyPos = 0, 0
+	a.ch3.sample = 0
+}
+
+// Cycles returns APU clocks(4MHz) since power on
+func (a *APU) Cycles() uint64 { return a.cycles }
+
+// InitWrites returns register writes which reproduce current APU state from power off, playing channels are triggered again
+func (a *APU) InitWrites() [][2]byte {
+	if !a.power {
+		return nil
+	}
+
+	writes := [][2]byte{{0x26, 0x80}}
+	for i, b := range a.ch3.ram {
+		writes = append(writes, [2]byte{0x30 + byte(i), b})
+	}
+	writes = append(writes, [2]byte{0x24, a.regs[0x24-0x10]}, [2]byte{0x25, a.regs[0x25-0x10]})
+
+	enabled := map[byte]bool{0x14: a.ch1.enabled, 0x19: a.ch2.enabled, 0x1e: a.ch3.enabled, 0x23: a.ch4.enabled}
+	for offset := byte(0x10); offset <= 0x23; offset++ {
+		value := a.regs[offset-0x10]
+		if on, ok := enabled[offset]; ok {
+			value = util.SetBit8(value, 7, on)
+		}
+		writes = append(writes, [2]byte{offset, value})
+	}
+	return writes
 }
 
-// Extract some envelope variables from a byte.
-func (a *APU) extractEnvelope(val byte) (volume, direction, sweep byte) {
-	volume = (val & 0xF0) >> 4
-	direction = (val & 0x8) >> 3 // 1 or 0
-	sweep = val & 0x7
-	return
+// ToggleSoundChannel toggles a sound channel for debugging.
+func (a *APU) ToggleSoundChannel(channel int) {
+	if channel < 1 || channel > 4 {
+		return
+	}
+	a.SetMute(channel, !a.mixer[channel-1].mute)
+	log.Printf("Toggle Channel %v mute", channel)
 }
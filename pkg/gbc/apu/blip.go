@@ -0,0 +1,93 @@
+package apu
+
+import "math"
+
+const (
+	blipWidth  = 16 // kernel taps
+	blipPhases = 64 // sub-sample resolution of kernel
+	blipCutoff = 0.9
+)
+
+// blipKernel is band-limited impulse table, each phase is normalized so that a step keeps its amplitude
+var blipKernel = func() (k [blipPhases][blipWidth]float64) {
+	for p := 0; p < blipPhases; p++ {
+		sum := 0.0
+		for i := 0; i < blipWidth; i++ {
+			x := float64(i) - blipWidth/2 + 0.5 - float64(p)/blipPhases
+			h := blipCutoff
+			if x != 0 {
+				h = math.Sin(math.Pi*x*blipCutoff) / (math.Pi * x)
+			}
+			// blackman window
+			w := 0.42 + 0.5*math.Cos(2*math.Pi*x/blipWidth) + 0.08*math.Cos(4*math.Pi*x/blipWidth)
+			k[p][i] = h * w
+			sum += k[p][i]
+		}
+		for i := range k[p] {
+			k[p][i] /= sum
+		}
+	}
+	return k
+}()
+
+// blip is band-limited step synthesizer (like blip_buf)
+//
+// APU adds amplitude changes at clock rate, and blip resamples them into host rate without aliasing.
+type blip struct {
+	factor     float64   // output samples per clock
+	offset     float64   // output position of current frame start
+	buf        []float64 // differences of output samples
+	integrator float64
+}
+
+func newBlip(clockRate, sampleRate float64) *blip {
+	return &blip{
+		factor: sampleRate / clockRate,
+		buf:    make([]float64, int(sampleRate/30)+blipWidth),
+	}
+}
+
+// addDelta adds amplitude change at clock time(relative to current frame)
+func (b *blip) addDelta(time uint64, delta float64) {
+	pos := b.offset + float64(time)*b.factor
+	i := int(pos)
+	phase := int((pos - float64(i)) * blipPhases)
+	b.grow(i)
+
+	out := b.buf[i : i+blipWidth]
+	for j, k := range blipKernel[phase] {
+		out[j] += delta * k
+	}
+}
+
+// endFrame makes samples in frame(clocks) available
+func (b *blip) endFrame(clocks uint64) {
+	b.offset += float64(clocks) * b.factor
+	b.grow(int(b.offset))
+}
+
+// grow makes buffer large enough for kernel at sample i
+func (b *blip) grow(i int) {
+	if i+blipWidth > len(b.buf) {
+		b.buf = append(b.buf, make([]float64, i+blipWidth-len(b.buf))...)
+	}
+}
+
+// avail returns the number of samples that won't be changed anymore
+func (b *blip) avail() int { return int(b.offset) }
+
+// read takes n samples out of buffer
+func (b *blip) read(out []float64) {
+	n := len(out)
+	for i := 0; i < n; i++ {
+		b.integrator += b.buf[i]
+		out[i] = b.integrator
+	}
+
+	copy(b.buf, b.buf[n:])
+	tail := b.buf[len(b.buf)-n:]
+	for i := range tail {
+		tail[i] = 0
+	}
+	b.offset -= float64(n)
+}
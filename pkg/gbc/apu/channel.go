@@ -1,126 +1,278 @@
 package apu
 
-import "math"
-
-// NewChannel returns a new sound channel using a sampling function.
-func NewChannel() *Channel {
-	return &Channel{}
-}
-
-// Channel represents one of four Gameboy sound channels.
-type Channel struct {
-	frequency float64
-	generator WaveGenerator
-	time      float64
-	amplitude float64
-
-	// Duration in samples
-	duration int
-	length   int
-
-	envelopeVolume     int
-	envelopeTime       int
-	envelopeSteps      int
-	envelopeStepsInit  int
-	envelopeSamples    int
-	envelopeIncreasing bool
-
-	sweepTime     float64
-	sweepStepLen  byte
-	sweepSteps    byte
-	sweepStep     byte
-	sweepIncrease bool
-
-	onL bool
-	onR bool
-	// Debug flag to turn off sound output
-	debugOff bool
-}
-
-// Sample returns a single sample for streaming the sound output. Each sample
-// will increase the internal timer based on the global sample rate.
-func (chn *Channel) Sample() (outputL, outputR float64) {
-	var output float64
-	step := chn.frequency * twoPi / float64(SAMPLE_RATE)
-	chn.time += step
-	if chn.shouldPlay() {
-		// Take the sample value from the generator
-		if !chn.debugOff {
-			output = float64(chn.generator(chn.time)) * chn.amplitude
+// channel is common part of 4 sound channels
+type channel struct {
+	enabled      bool // NR52 status bit
+	dac          bool
+	length       int
+	lengthMax    int // 64 or 256
+	lengthEnable bool
+}
+
+func (c *channel) clockLength() {
+	if c.lengthEnable && c.length > 0 {
+		c.length--
+		if c.length == 0 {
+			c.enabled = false
 		}
-		if chn.duration > 0 {
-			chn.duration--
+	}
+}
+
+// dacOutput converts digital value(0..15) into analog value(-1..1)
+func (c *channel) dacOutput(digital int) float64 {
+	if !c.dac {
+		return 0
+	}
+	return float64(digital)/7.5 - 1
+}
+
+// volume envelope of square and noise channels
+type envelope struct {
+	initial  int
+	increase bool
+	period   int
+
+	volume int
+	timer  int
+}
+
+// NRx2: VVVV APPP Starting volume, Envelope add mode, period
+func (e *envelope) write(value byte) {
+	e.initial = int(value >> 4)
+	e.increase = value&0x08 != 0
+	e.period = int(value & 0x07)
+}
+
+func (e *envelope) trigger() {
+	e.volume = e.initial
+	e.timer = e.period
+	if e.timer == 0 {
+		e.timer = 8
+	}
+}
+
+func (e *envelope) clock() {
+	if e.period == 0 {
+		return
+	}
+	e.timer--
+	if e.timer > 0 {
+		return
+	}
+	e.timer = e.period
+
+	if e.increase && e.volume < 15 {
+		e.volume++
+	} else if !e.increase && e.volume > 0 {
+		e.volume--
+	}
+}
+
+var dutyTable = [4][8]int{
+	{0, 0, 0, 0, 0, 0, 0, 1}, // 12.5%
+	{1, 0, 0, 0, 0, 0, 0, 1}, // 25%
+	{1, 0, 0, 0, 0, 1, 1, 1}, // 50%
+	{0, 1, 1, 1, 1, 1, 1, 0}, // 75%
+}
+
+// square is channel 1 and 2, channel 1 also has frequency sweep
+type square struct {
+	channel
+	env     envelope
+	duty    int
+	dutyPos int
+	freq    int // 11bit
+	timer   int
+
+	sweepPeriod  int
+	sweepNegate  bool
+	sweepShift   int
+	sweepTimer   int
+	sweepEnabled bool
+	shadow       int
+	negateUsed   bool // negate mode has been used for calculation since last trigger
+}
+
+func (s *square) tick(dots int) {
+	s.timer -= dots
+	for s.timer <= 0 {
+		s.timer += (2048 - s.freq) * 4
+		s.dutyPos = (s.dutyPos + 1) & 7
+	}
+}
+
+func (s *square) trigger() {
+	s.timer = (2048 - s.freq) * 4
+	s.env.trigger()
+}
+
+func (s *square) output() float64 { return s.dacOutput(s.digital()) }
+
+// digital returns 4bit output before DAC
+func (s *square) digital() int {
+	if !s.enabled {
+		return 0
+	}
+	return dutyTable[s.duty][s.dutyPos] * s.env.volume
+}
+
+// NR10: -PPP NSSS Sweep period, negate, shift
+func (s *square) writeSweep(value byte) {
+	negate := value&0x08 != 0
+	if s.sweepNegate && !negate && s.negateUsed {
+		// clearing negate mode after calculation in negate mode disables channel
+		s.enabled = false
+	}
+	s.sweepPeriod = int(value>>4) & 7
+	s.sweepNegate = negate
+	s.sweepShift = int(value & 7)
+}
+
+func (s *square) triggerSweep() {
+	s.shadow = s.freq
+	s.sweepTimer = s.sweepPeriod
+	if s.sweepTimer == 0 {
+		s.sweepTimer = 8
+	}
+	s.sweepEnabled = s.sweepPeriod != 0 || s.sweepShift != 0
+	s.negateUsed = false
+	if s.sweepShift != 0 {
+		s.calcSweep()
+	}
+}
+
+func (s *square) clockSweep() {
+	s.sweepTimer--
+	if s.sweepTimer > 0 {
+		return
+	}
+	s.sweepTimer = s.sweepPeriod
+	if s.sweepTimer == 0 {
+		s.sweepTimer = 8
+	}
+
+	if s.sweepEnabled && s.sweepPeriod != 0 {
+		freq := s.calcSweep()
+		if freq <= 2047 && s.sweepShift != 0 {
+			s.shadow, s.freq = freq, freq
+			// overflow check again with new frequency
+			s.calcSweep()
 		}
 	}
-	chn.updateEnvelope()
-	chn.updateSweep()
-	if chn.onL {
-		outputL = output
-	}
-	if chn.onR {
-		outputR = output
-	}
-	return
-}
-
-// Reset the channel to some default variables for the sweep, amplitude,
-// envelope and duration.
-func (chn *Channel) Reset(duration int) {
-	chn.amplitude = 1
-	chn.envelopeTime = 0
-	chn.sweepTime = 0
-	chn.sweepStep = 0
-	chn.duration = duration
-}
-
-// Returns if the channel should be playing or not.
-func (chn *Channel) shouldPlay() bool {
-	return (chn.duration == -1 || chn.duration > 0) &&
-		chn.generator != nil && chn.envelopeStepsInit > 0
-}
-
-// Update the state of the channels envelope.
-func (chn *Channel) updateEnvelope() {
-	if chn.envelopeSamples > 0 {
-		chn.envelopeTime += 1
-		if chn.envelopeSteps > 0 && chn.envelopeTime >= chn.envelopeSamples {
-			chn.envelopeTime -= chn.envelopeSamples
-			chn.envelopeSteps--
-			if chn.envelopeSteps == 0 {
-				chn.amplitude = 0
-			} else if chn.envelopeIncreasing {
-				chn.amplitude = 1 - float64(chn.envelopeSteps)/float64(chn.envelopeStepsInit)
-			} else {
-				chn.amplitude = float64(chn.envelopeSteps) / float64(chn.envelopeStepsInit)
-			}
+}
+
+// calcSweep returns new frequency, channel is disabled if it overflows
+func (s *square) calcSweep() int {
+	freq := s.shadow >> s.sweepShift
+	if s.sweepNegate {
+		freq = s.shadow - freq
+		s.negateUsed = true
+	} else {
+		freq = s.shadow + freq
+	}
+	if freq > 2047 {
+		s.enabled = false
+	}
+	return freq
+}
+
+var waveShift = [4]int{4, 0, 1, 2} // mute, 100%, 50%, 25%
+
+// wave is channel 3
+type wave struct {
+	channel
+	volume int // volume code
+	freq   int
+	timer  int
+	pos    int  // sample position (0..31)
+	sample byte // sample buffer
+	readAt uint64
+	ram    [16]byte
+}
+
+func (w *wave) tick(dots int, now uint64) {
+	w.timer -= dots
+	for w.timer <= 0 {
+		w.readAt = now - uint64(-w.timer)
+		w.timer += (2048 - w.freq) * 2
+		w.pos = (w.pos + 1) & 31
+		w.sample = w.ram[w.pos>>1]
+		if w.pos&1 == 0 {
+			w.sample >>= 4
 		}
+		w.sample &= 0xf
 	}
 }
 
-var sweepTimes = map[byte]float64{
-	1: 7.8 / 1000,
-	2: 15.6 / 1000,
-	3: 23.4 / 1000,
-	4: 31.3 / 1000,
-	5: 39.1 / 1000,
-	6: 46.9 / 1000,
-	7: 54.7 / 1000,
+// trigger wave channel, first sample is read after 3 APU cycles delay
+func (w *wave) trigger() {
+	w.timer = (2048-w.freq)*2 + 6
+	w.pos = 0
 }
 
-// Update the state of the channels sweep.
-func (chn *Channel) updateSweep() {
-	if chn.sweepStep < chn.sweepSteps {
-		t := sweepTimes[chn.sweepStepLen]
-		chn.sweepTime += perSample
-		if chn.sweepTime > t {
-			chn.sweepTime -= t
-			chn.sweepStep += 1
+// corrupt emulates DMG wave RAM corruption by triggering channel 3 while it's reading wave RAM
+func (w *wave) corrupt() {
+	if !w.enabled || w.timer > 2 {
+		return
+	}
+	idx := ((w.pos + 1) & 31) >> 1
+	if idx < 4 {
+		w.ram[0] = w.ram[idx]
+		return
+	}
+	copy(w.ram[0:4], w.ram[idx&^3:idx&^3+4])
+}
+
+func (w *wave) output() float64 { return w.dacOutput(w.digital()) }
+
+func (w *wave) digital() int {
+	if !w.enabled {
+		return 0
+	}
+	return int(w.sample) >> waveShift[w.volume]
+}
+
+var noiseDivisor = [8]int{8, 16, 32, 48, 64, 80, 96, 112}
+
+// noise is channel 4
+type noise struct {
+	channel
+	env     envelope
+	shift   int
+	width7  bool // 7bit LFSR
+	divisor int
+	timer   int
+	lfsr    uint16
+}
+
+func (n *noise) tick(dots int) {
+	n.timer -= dots
+	for n.timer <= 0 {
+		n.timer += noiseDivisor[n.divisor] << n.shift
+		if n.shift >= 14 {
+			// LFSR isn't clocked
+			continue
+		}
 
-			if chn.sweepIncrease {
-				chn.frequency += chn.frequency / math.Pow(2, float64(chn.sweepStep))
-			} else {
-				chn.frequency -= chn.frequency / math.Pow(2, float64(chn.sweepStep))
-			}
+		xor := (n.lfsr ^ (n.lfsr >> 1)) & 1
+		n.lfsr = (n.lfsr >> 1) | (xor << 14)
+		if n.width7 {
+			n.lfsr = (n.lfsr &^ (1 << 6)) | (xor << 6)
 		}
 	}
 }
+
+func (n *noise) trigger() {
+	n.timer = noiseDivisor[n.divisor] << n.shift
+	n.lfsr = 0x7fff
+	n.env.trigger()
+}
+
+func (n *noise) output() float64 { return n.dacOutput(n.digital()) }
+
+func (n *noise) digital() int {
+	if !n.enabled || n.lfsr&1 != 0 {
+		return 0
+	}
+	return n.env.volume
+}
@@ -0,0 +1,43 @@
+package apu
+
+// mixerChannel is debug controls of a sound channel, it doesn't affect emulated hardware state
+type mixerChannel struct {
+	mute bool
+	gain float64
+}
+
+// SetMute mutes/unmutes channel(1..4)
+func (a *APU) SetMute(channel int, mute bool) {
+	if channel >= 1 && channel <= 4 {
+		a.mixer[channel-1].mute = mute
+	}
+}
+
+// SetSolo makes only channel(1..4) audible, 0 clears solo
+func (a *APU) SetSolo(channel int) {
+	if channel >= 0 && channel <= 4 {
+		a.solo = channel
+	}
+}
+
+func (a *APU) Solo() int { return a.solo }
+
+// CopyMixer takes over mute, solo and gain from src(e.g. on reset)
+func (a *APU) CopyMixer(src *APU) {
+	a.mixer, a.solo = src.mixer, src.solo
+}
+
+// SetGain sets channel(1..4) output gain, 1 is hardware volume
+func (a *APU) SetGain(channel int, gain float64) {
+	if channel >= 1 && channel <= 4 && gain >= 0 {
+		a.mixer[channel-1].gain = gain
+	}
+}
+
+// channelGain returns gain of channel i(0..3) considering mute and solo
+func (a *APU) channelGain(i int) float64 {
+	if a.mixer[i].mute || (a.solo != 0 && a.solo != i+1) {
+		return 0
+	}
+	return a.mixer[i].gain
+}
@@ -0,0 +1,66 @@
+package apu
+
+// output is band-limited stereo output with high-pass filter, mixed output and each channel stem have this
+type output struct {
+	blip      [2]*blip   // left, right
+	level     [2]float64 // current analog output
+	capacitor [2]float64 // high-pass filter
+
+	samples []float64 // reused by read
+	result  []int16
+}
+
+func newOutput(sampleRate int) *output {
+	return &output{
+		blip: [2]*blip{newBlip(CLOCK, float64(sampleRate)), newBlip(CLOCK, float64(sampleRate))},
+	}
+}
+
+// set passes output changes to blip, time is clocks from frame start
+func (o *output) set(time uint64, left, right float64) {
+	for c, level := range [2]float64{left, right} {
+		if delta := level - o.level[c]; delta != 0 {
+			o.blip[c].addDelta(time, delta)
+			o.level[c] = level
+		}
+	}
+}
+
+func (o *output) setFactor(factor float64) {
+	o.blip[0].factor, o.blip[1].factor = factor, factor
+}
+
+// read ends frame and returns signed 16bit interleaved stereo samples, result is valid until next read
+func (o *output) read(clocks uint64, charge float64) []int16 {
+	for _, b := range o.blip {
+		b.endFrame(clocks)
+	}
+
+	n := o.blip[0].avail()
+	if cap(o.samples) < n {
+		o.samples, o.result = make([]float64, n), make([]int16, n*2)
+	}
+	samples, result := o.samples[:n], o.result[:n*2]
+	for c, b := range o.blip {
+		b.read(samples)
+		for i, in := range samples {
+			// DMG/CGB output has a capacitor which removes DC offset
+			out := in - o.capacitor[c]
+			o.capacitor[c] = in - out*charge
+			result[i*2+c] = toInt16(out)
+		}
+	}
+	return result
+}
+
+// toInt16 converts analog output(-8..8 after high-pass filter) into signed 16bit
+func toInt16(v float64) int16 {
+	v *= 32767 / 8.0
+	if v > 32767 {
+		return 32767
+	}
+	if v < -32768 {
+		return -32768
+	}
+	return int16(v)
+}
@@ -0,0 +1,93 @@
+package apu
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	ScopeRate     = CLOCK / scopeInterval // scope sample rate(65536Hz)
+	ScopeLen      = 2048
+	scopeInterval = 64
+)
+
+// scope keeps recent channel outputs for debugger
+type scope struct {
+	timer int
+	pos   int
+	buf   [5][ScopeLen]float32 // channel 1..4, mixed
+
+	mu   sync.Mutex
+	view [5][ScopeLen]float32 // buf at the end of last frame, oldest first
+}
+
+// EnableScope starts/stops capturing channel outputs, it is safe to call from other goroutines
+//
+// The change is applied on next frame.
+func (a *APU) EnableScope(on bool) {
+	v := int32(0)
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&a.scopeOn, v)
+}
+
+// updateScope applies EnableScope and publishes captured outputs, it is called on frame end
+func (a *APU) updateScope() {
+	on := atomic.LoadInt32(&a.scopeOn) == 1
+	switch {
+	case on && a.scope == nil:
+		a.scope = &scope{timer: scopeInterval}
+		a.scopeView.Store(a.scope)
+	case !on && a.scope != nil:
+		a.scope = nil
+		a.scopeView.Store(a.scope)
+	}
+
+	if s := a.scope; s != nil {
+		s.mu.Lock()
+		for i := range s.view {
+			n := copy(s.view[i][:], s.buf[i][s.pos:])
+			copy(s.view[i][n:], s.buf[i][:s.pos])
+		}
+		s.mu.Unlock()
+	}
+}
+
+// capture records current outputs for dots
+func (s *scope) capture(a *APU, dots int) {
+	s.timer -= dots
+	if s.timer > 0 {
+		return
+	}
+
+	outs := [5]float32{
+		float32(a.ch1.output()), float32(a.ch2.output()), float32(a.ch3.output()), float32(a.ch4.output()),
+		float32(a.mix.level[0]+a.mix.level[1]) / 2,
+	}
+	for ; s.timer <= 0; s.timer += scopeInterval {
+		for i, out := range outs {
+			s.buf[i][s.pos] = out
+		}
+		s.pos = (s.pos + 1) % ScopeLen
+	}
+}
+
+// Scope returns recent ScopeLen outputs of each channel(-1..1) and mixed output at the end of last frame, oldest first
+//
+// It returns nil if scope isn't enabled. It is safe to call from other goroutines.
+func (a *APU) Scope() (channels [4][]float32, mix []float32) {
+	s, _ := a.scopeView.Load().(*scope)
+	if s == nil {
+		return channels, nil
+	}
+
+	var result [5][]float32
+	s.mu.Lock()
+	for i := range result {
+		result[i] = append([]float32(nil), s.view[i][:]...)
+	}
+	s.mu.Unlock()
+	copy(channels[:], result[:4])
+	return channels, result[4]
+}
@@ -0,0 +1,123 @@
+package apu
+
+import "github.com/pokemium/worldwide/pkg/util"
+
+// State is live APU state for debugging
+type State struct {
+	Power       bool            `json:"power"`
+	LeftVolume  int             `json:"left_volume"` // NR50
+	RightVolume int             `json:"right_volume"`
+	Solo        int             `json:"solo"`
+	Channels    [4]ChannelState `json:"channels"`
+}
+
+type ChannelState struct {
+	Channel      int            `json:"channel"`
+	Enabled      bool           `json:"enabled"`
+	DAC          bool           `json:"dac"`
+	Frequency    int            `json:"frequency"` // 11bit frequency register, noise channel has no frequency register
+	Hz           float64        `json:"hz"`
+	Duty         string         `json:"duty,omitempty"`
+	Volume       int            `json:"volume"` // current envelope volume, or volume code on channel 3
+	Envelope     *EnvelopeState `json:"envelope,omitempty"`
+	Sweep        *SweepState    `json:"sweep,omitempty"`
+	Noise        *NoiseState    `json:"noise,omitempty"`
+	Position     int            `json:"position"` // duty step or wave RAM sample position
+	Length       int            `json:"length"`   // remaining length counter
+	LengthEnable bool           `json:"length_enable"`
+	Left         bool           `json:"left"` // NR51 panning
+	Right        bool           `json:"right"`
+	Mute         bool           `json:"mute"`
+	Gain         float64        `json:"gain"`
+}
+
+type EnvelopeState struct {
+	Initial  int  `json:"initial"`
+	Increase bool `json:"increase"`
+	Period   int  `json:"period"`
+}
+
+type SweepState struct {
+	Period int  `json:"period"`
+	Negate bool `json:"negate"`
+	Shift  int  `json:"shift"`
+	Shadow int  `json:"shadow"`
+}
+
+type NoiseState struct {
+	Shift   int    `json:"shift"`
+	Width   int    `json:"width"` // LFSR width, 7 or 15
+	Divisor int    `json:"divisor"`
+	LFSR    uint16 `json:"lfsr"`
+}
+
+var dutyNames = [4]string{"12.5%", "25%", "50%", "75%"}
+
+// State returns live APU state
+func (a *APU) State() State {
+	nr50, nr51 := a.regs[0x24-0x10], a.regs[0x25-0x10]
+	s := State{
+		Power:       a.power,
+		LeftVolume:  int(nr50>>4) & 7,
+		RightVolume: int(nr50 & 7),
+		Solo:        a.solo,
+	}
+
+	s.Channels[0] = a.ch1.state()
+	s.Channels[0].Sweep = &SweepState{a.ch1.sweepPeriod, a.ch1.sweepNegate, a.ch1.sweepShift, a.ch1.shadow}
+	s.Channels[1] = a.ch2.state()
+	s.Channels[2] = a.ch3.state()
+	s.Channels[3] = a.ch4.state()
+	for i := range s.Channels {
+		c := &s.Channels[i]
+		c.Channel = i + 1
+		c.Left, c.Right = util.Bit(nr51, i+4), util.Bit(nr51, i)
+		c.Mute, c.Gain = a.mixer[i].mute, a.mixer[i].gain
+	}
+	return s
+}
+
+func (c *channel) state() ChannelState {
+	return ChannelState{
+		Enabled:      c.enabled,
+		DAC:          c.dac,
+		Length:       c.length,
+		LengthEnable: c.lengthEnable,
+	}
+}
+
+func (e *envelope) state() *EnvelopeState {
+	return &EnvelopeState{e.initial, e.increase, e.period}
+}
+
+func (s *square) state() ChannelState {
+	state := s.channel.state()
+	state.Frequency = s.freq
+	state.Hz = float64(CLOCK) / float64((2048-s.freq)*32)
+	state.Duty = dutyNames[s.duty]
+	state.Volume = s.env.volume
+	state.Envelope = s.env.state()
+	state.Position = s.dutyPos
+	return state
+}
+
+func (w *wave) state() ChannelState {
+	state := w.channel.state()
+	state.Frequency = w.freq
+	state.Hz = float64(CLOCK) / float64((2048-w.freq)*64)
+	state.Volume = w.volume
+	state.Position = w.pos
+	return state
+}
+
+func (n *noise) state() ChannelState {
+	state := n.channel.state()
+	state.Hz = float64(CLOCK) / float64(noiseDivisor[n.divisor]<<n.shift)
+	state.Volume = n.env.volume
+	state.Envelope = n.env.state()
+	state.Noise = &NoiseState{Shift: n.shift, Width: 15, Divisor: n.divisor, LFSR: n.lfsr}
+	if n.width7 {
+		state.Noise.Width = 7
+	}
+	return state
+}
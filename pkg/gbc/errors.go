@@ -0,0 +1,50 @@
+package gbc
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// CartridgeError is returned by New when the cartridge can't be loaded
+type CartridgeError struct {
+	Type, ROMSize, RAMSize byte
+	Reason                 string
+}
+
+func (e *CartridgeError) Error() string {
+	return fmt.Sprintf("%s => type:%x rom:%x ram:%x", e.Reason, e.Type, e.ROMSize, e.RAMSize)
+}
+
+// Fault is emulation error returned by Step and Update
+type Fault struct {
+	Inst  CurInst // instruction running when the fault occurred
+	Err   error
+	Stack []byte // Go stack trace, this is empty if the fault is not caused by panic
+}
+
+func (f *Fault) Error() string {
+	return fmt.Sprintf("emulation error: %s in 0x%04x(opcode: 0x%02x)", f.Err, f.Inst.PC, f.Inst.Opcode)
+}
+
+func (f *Fault) Unwrap() error { return f.Err }
+
+// recoverFault converts panic in emulation into *Fault
+func (g *GBC) recoverFault(err *error) {
+	if r := recover(); r != nil {
+		e, ok := r.(error)
+		if !ok {
+			e = fmt.Errorf("%v", r)
+		}
+		*err = &Fault{Inst: g.Inst, Err: e, Stack: debug.Stack()}
+	}
+}
+
+// fault returns error recorded while executing an instruction
+func (g *GBC) fault() error {
+	if g.Reg.err == nil {
+		return nil
+	}
+	err := &Fault{Inst: g.Inst, Err: g.Reg.err}
+	g.Reg.err = nil
+	return err
+}
@@ -3,8 +3,6 @@ package gbc
 import (
 	"fmt"
 	"math"
-	"os"
-	"runtime"
 
 	"github.com/pokemium/worldwide/pkg/gbc/apu"
 	"github.com/pokemium/worldwide/pkg/gbc/cart"
@@ -47,12 +45,32 @@ type WRAM struct {
 type Dma struct {
 	src, dest uint16
 	remaining int
+	value     byte // byte on source bus, CPU reads this on bus conflict
 }
 
+type hdmaState byte
+
+const (
+	hdmaIdle    hdmaState = iota
+	hdmaWaiting           // HBlank DMA waits for next HBlank
+	hdmaCopying           // a block is being copied, CPU is stalled
+)
+
+// Hdma is CGB VRAM DMA, it copies 0x10 bytes block at 2 dots per byte in both speed modes
 type Hdma struct {
-	enable    bool
+	state     hdmaState
+	hblank    bool // HBlank DMA copies a block per HBlank, general purpose DMA copies all blocks at once
 	src, dest uint16
-	remaining int
+	blocks    int // remaining blocks
+	remaining int // remaining bytes in current block
+}
+
+// HDMAStatus is HDMA state for debugger
+type HDMAStatus struct {
+	State     string // "idle", "waiting" or "copying"
+	HBlank    bool
+	Src, Dest uint16
+	Remaining int // remaining bytes
 }
 
 const (
@@ -69,6 +87,36 @@ type CurInst struct {
 	PC     uint16
 }
 
+// Lockup is CPU hard lock by illegal opcode
+type Lockup struct {
+	PC     uint16
+	Opcode byte
+}
+
+func (l *Lockup) String() string {
+	return fmt.Sprintf("CPU locked at PC=0x%04x opcode=0x%02x", l.PC, l.Opcode)
+}
+
+// Violation is CPU access to memory which PPU or OAM DMA is using
+type Violation struct {
+	PC, Addr uint16
+	Write    bool
+	Mode     byte // PPU mode
+	Ly       int
+	DMA      bool // OAM DMA is running
+}
+
+func (v Violation) String() string {
+	access := "read"
+	if v.Write {
+		access = "write"
+	}
+	if v.DMA {
+		return fmt.Sprintf("0x%04x: %s 0x%04x during OAM DMA", v.PC, access, v.Addr)
+	}
+	return fmt.Sprintf("0x%04x: %s 0x%04x in mode %d (LY=%d)", v.PC, access, v.Addr, v.Mode, v.Ly)
+}
+
 // GBC core structure
 type GBC struct {
 	Reg  Register
@@ -83,6 +131,8 @@ type GBC struct {
 	Cartridge   *cart.Cartridge
 	joypad      *joypad.Joypad
 	Halt        bool
+	Stop        bool    // STOP mode, CPU and LCD stop until a selected joypad line goes low
+	Lockup      *Lockup // not nil if CPU is locked up
 	timer       *Timer
 	bankMode    uint
 	Sound       *apu.APU
@@ -95,25 +145,30 @@ type GBC struct {
 	dma         Dma
 	hdma        Hdma
 	cpuBlocked  bool
+	speedSwitch bool        // CPU is stalled on CGB speed switch
+	haltBug     bool        // next opcode is read twice
+	haltExit    bool        // CPU is waking up from HALT mode
+	recent      [32]CurInst // ring buffer of recently executed instructions
+	recentIdx   int
 
 	// plugins
-	Callbacks []*util.Callback
+	Callbacks   []*util.Callback
+	OnViolation func(v Violation) // called on blocked VRAM/OAM/palette access if not nil
 }
 
 // TransferROM Transfer ROM from cartridge to Memory
-func (g *GBC) TransferROM(rom []byte) {
+func (g *GBC) TransferROM(rom []byte) error {
 	switch g.Cartridge.Type {
 	case 0x00:
 		g.Cartridge.MBC = cart.ROM
-		g.transferROM(2, rom)
+		return g.transferROM(2, rom)
 	case 0x01: // Type : 1 => MBC1
 		g.Cartridge.MBC = cart.MBC1
 		switch r := int(g.Cartridge.ROMSize); r {
 		case 0, 1, 2, 3, 4, 5, 6:
-			g.transferROM(int(math.Pow(2, float64(r+1))), rom)
+			return g.transferROM(int(math.Pow(2, float64(r+1))), rom)
 		default:
-			errorMsg := fmt.Sprintf("ROMSize is invalid => type:%x rom:%x ram:%x\n", g.Cartridge.Type, g.Cartridge.ROMSize, g.Cartridge.RAMSize)
-			panic(errorMsg)
+			return g.cartridgeError("ROMSize is invalid")
 		}
 	case 0x02, 0x03: // Type : 2, 3 => MBC1+RAM
 		g.Cartridge.MBC = cart.MBC1
@@ -121,24 +176,21 @@ func (g *GBC) TransferROM(rom []byte) {
 		case 0, 1, 2:
 			switch r := int(g.Cartridge.ROMSize); r {
 			case 0, 1, 2, 3, 4, 5, 6:
-				g.transferROM(int(math.Pow(2, float64(r+1))), rom)
+				return g.transferROM(int(math.Pow(2, float64(r+1))), rom)
 			default:
-				errorMsg := fmt.Sprintf("ROMSize is invalid => type:%x rom:%x ram:%x\n", g.Cartridge.Type, g.Cartridge.ROMSize, g.Cartridge.RAMSize)
-				panic(errorMsg)
+				return g.cartridgeError("ROMSize is invalid")
 			}
 		case 3:
 			g.bankMode = 1
 			switch r := int(g.Cartridge.ROMSize); r {
 			case 0:
 			case 1, 2, 3, 4:
-				g.transferROM(int(math.Pow(2, float64(r+1))), rom)
+				return g.transferROM(int(math.Pow(2, float64(r+1))), rom)
 			default:
-				errorMsg := fmt.Sprintf("ROMSize is invalid => type:%x rom:%x ram:%x\n", g.Cartridge.Type, g.Cartridge.ROMSize, g.Cartridge.RAMSize)
-				panic(errorMsg)
+				return g.cartridgeError("ROMSize is invalid")
 			}
 		default:
-			errorMsg := fmt.Sprintf("RAMSize is invalid => type:%x rom:%x ram:%x\n", g.Cartridge.Type, g.Cartridge.ROMSize, g.Cartridge.RAMSize)
-			panic(errorMsg)
+			return g.cartridgeError("RAMSize is invalid")
 		}
 	case 0x05, 0x06: // Type : 5, 6 => MBC2
 		g.Cartridge.MBC = cart.MBC2
@@ -146,55 +198,59 @@ func (g *GBC) TransferROM(rom []byte) {
 		case 0, 1, 2:
 			switch r := int(g.Cartridge.ROMSize); r {
 			case 0, 1, 2, 3:
-				g.transferROM(int(math.Pow(2, float64(r+1))), rom)
+				return g.transferROM(int(math.Pow(2, float64(r+1))), rom)
 			default:
-				errorMsg := fmt.Sprintf("ROMSize is invalid => type:%x rom:%x ram:%x\n", g.Cartridge.Type, g.Cartridge.ROMSize, g.Cartridge.RAMSize)
-				panic(errorMsg)
+				return g.cartridgeError("ROMSize is invalid")
 			}
 		case 3:
 			g.bankMode = 1
 			switch r := int(g.Cartridge.ROMSize); r {
 			case 0:
 			case 1, 2, 3:
-				g.transferROM(int(math.Pow(2, float64(r+1))), rom)
+				return g.transferROM(int(math.Pow(2, float64(r+1))), rom)
 			default:
-				errorMsg := fmt.Sprintf("ROMSize is invalid => type:%x rom:%x ram:%x\n", g.Cartridge.Type, g.Cartridge.ROMSize, g.Cartridge.RAMSize)
-				panic(errorMsg)
+				return g.cartridgeError("ROMSize is invalid")
 			}
 		default:
-			errorMsg := fmt.Sprintf("RAMSize is invalid => type:%x rom:%x ram:%x\n", g.Cartridge.Type, g.Cartridge.ROMSize, g.Cartridge.RAMSize)
-			panic(errorMsg)
+			return g.cartridgeError("RAMSize is invalid")
 		}
 	case 0x0f, 0x10, 0x11, 0x12, 0x13: // Type : 0x0f, 0x10, 0x11, 0x12, 0x13 => MBC3
 		g.Cartridge.MBC, g.RTC.Enable = cart.MBC3, true
 		switch r := int(g.Cartridge.ROMSize); r {
 		case 0, 1, 2, 3, 4, 5, 6:
-			g.transferROM(int(math.Pow(2, float64(r+1))), rom)
+			return g.transferROM(int(math.Pow(2, float64(r+1))), rom)
 		default:
-			errorMsg := fmt.Sprintf("ROMSize is invalid => type:%x rom:%x ram:%x\n", g.Cartridge.Type, g.Cartridge.ROMSize, g.Cartridge.RAMSize)
-			panic(errorMsg)
+			return g.cartridgeError("ROMSize is invalid")
 		}
 	case 0x19, 0x1a, 0x1b: // Type : 0x19, 0x1a, 0x1b => MBC5
 		g.Cartridge.MBC = cart.MBC5
 		switch r := int(g.Cartridge.ROMSize); r {
 		case 0, 1, 2, 3, 4, 5, 6, 7:
-			g.transferROM(int(math.Pow(2, float64(r+1))), rom)
+			return g.transferROM(int(math.Pow(2, float64(r+1))), rom)
 		default:
-			errorMsg := fmt.Sprintf("ROMSize is invalid => type:%x rom:%x ram:%x\n", g.Cartridge.Type, g.Cartridge.ROMSize, g.Cartridge.RAMSize)
-			panic(errorMsg)
+			return g.cartridgeError("ROMSize is invalid")
 		}
 	default:
-		errorMsg := fmt.Sprintf("Type is invalid => type:%x rom:%x ram:%x\n", g.Cartridge.Type, g.Cartridge.ROMSize, g.Cartridge.RAMSize)
-		panic(errorMsg)
+		return g.cartridgeError("Type is invalid")
 	}
+	return nil
 }
 
-func (g *GBC) transferROM(bankNum int, rom []byte) {
+func (g *GBC) transferROM(bankNum int, rom []byte) error {
+	if len(rom) < bankNum*0x4000 {
+		return g.cartridgeError("ROM data is too short")
+	}
+
 	for bank := 0; bank < bankNum; bank++ {
 		for i := 0x0000; i <= 0x3fff; i++ {
 			g.ROM.buffer[bank][i] = rom[bank*0x4000+i]
 		}
 	}
+	return nil
+}
+
+func (g *GBC) cartridgeError(reason string) error {
+	return &CartridgeError{Type: g.Cartridge.Type, ROMSize: g.Cartridge.ROMSize, RAMSize: g.Cartridge.RAMSize, Reason: reason}
 }
 
 func (g *GBC) resetRegister() {
@@ -205,7 +261,12 @@ func (g *GBC) resetRegister() {
 	g.Reg.PC, g.Reg.SP = 0x0100, 0xfffe
 }
 
-func New(romData []byte, j [8](func() bool), setAudioStream func([]byte)) *GBC {
+// New returns *CartridgeError if romData is not supported
+func New(romData []byte, j [8](func() bool), setAudioStream func([]int16)) (*GBC, error) {
+	if len(romData) < 0x150 {
+		return nil, &CartridgeError{Reason: "ROM header is missing"}
+	}
+
 	c := cart.New(romData)
 	g := &GBC{
 		Cartridge: c,
@@ -224,47 +285,92 @@ func New(romData []byte, j [8](func() bool), setAudioStream func([]byte)) *GBC {
 	// init timer
 	g.timer = NewTimer(g)
 	g.skipBIOS()
-	g.TransferROM(romData)
-	return g
+	if err := g.TransferROM(romData); err != nil {
+		return nil, err
+	}
+	return g, nil
 }
 
-// Exec 1cycle
-func (g *GBC) Step() {
+// Step executes 1 instruction
+//
+// Emulation error is returned as *Fault.
+func (g *GBC) Step() (err error) {
+	defer g.recoverFault(&err)
+	g.step()
+	return g.fault()
+}
+
+func (g *GBC) step() {
+	if g.Stop {
+		return
+	}
+
 	pc := g.Reg.PC
-	opcode := g.Load8(pc)
-	g.Inst.Opcode, g.Inst.PC = opcode, pc
+	g.Inst.Opcode, g.Inst.PC = g.Load8(pc), pc
 
-	inst := gbz80insts[opcode]
-	operand1, operand2, cycle, handler := inst.Operand1, inst.Operand2, inst.Cycle1, inst.Handler
+	if g.Halt || g.cpuBlocked || g.speedSwitch || g.Lockup != nil {
+		g.timer.tick(uint32(g.scheduler.Next() - g.scheduler.Cycle()))
+		return
+	}
 
-	if g.Halt || g.cpuBlocked {
-		cycle = int(g.scheduler.Next() - g.scheduler.Cycle())
-	} else {
-		if g.irqPending > 0 {
-			oldIrqPending := g.irqPending
-			g.irqPending = 0
-			g.setInterrupts(false)
-			g.triggerIRQ(int(oldIrqPending - 1))
-			return
+	if g.haltExit {
+		// DMG takes an extra M-cycle to exit HALT mode, CGB doesn't
+		g.haltExit = false
+		if g.model < util.GB_MODEL_CGB {
+			g.timer.tick(g.fixCycles(1))
+		}
+	}
+	if g.irqPending > 0 {
+		g.irqPending = 0
+		g.setInterrupts(false)
+		if g.haltBug {
+			// `EI; HALT` with pending interrupt, HALT is executed again after returning from interrupt
+			g.haltBug = false
+			g.Reg.PC--
 		}
+		g.triggerIRQ()
+		return
+	}
 
+	if g.blocked(pc) {
+		// opcode can't be fetched from the bus OAM DMA is using
+		g.violate(pc, false)
+		g.Inst.Opcode = 0xff
+	}
+
+	g.recent[g.recentIdx] = g.Inst
+	g.recentIdx = (g.recentIdx + 1) % len(g.recent)
+
+	if g.haltBug {
+		g.haltBug = false
+	} else {
 		g.Reg.PC++
-		handler(g, operand1, operand2)
-		cycle *= (4 >> uint32(util.Bool2U64(g.DoubleSpeed)))
 	}
 
-	g.timer.tick(uint32(cycle))
+	// opcode fetch is the first M-cycle, handler adds M-cycles for each memory access
+	inst := gbz80insts[g.Inst.Opcode]
+	inst.Handler(g, inst.Operand1, inst.Operand2)
+	g.timer.tick(g.fixCycles(1))
 }
 
-// 1 frame
-func (g *GBC) Update() {
+// Update runs 1 frame
+//
+// Emulation error is returned as *Fault.
+func (g *GBC) Update() (err error) {
+	defer g.recoverFault(&err)
+
 	frame := g.Frame()
-	if frame%3 == 0 {
-		g.handleJoypad()
+	g.handleJoypad()
+	if g.Stop && g.joypad.Low() {
+		g.Stop = false
 	}
 
-	for frame == g.Video.FrameCounter {
-		g.Step()
+	// on STOP mode, nothing runs until joypad input
+	for frame == g.Video.FrameCounter && !g.Stop {
+		g.step()
+		if err := g.fault(); err != nil {
+			return err
+		}
 
 		// trigger callbacks
 		for _, callback := range g.Callbacks {
@@ -275,25 +381,26 @@ func (g *GBC) Update() {
 	}
 
 	g.Sound.Update()
+	return nil
 }
 
-func (g *GBC) PanicHandler(place string, stack bool) {
-	if err := recover(); err != nil {
-		fmt.Fprintf(os.Stderr, "%s emulation error: %s in 0x%04x\n", place, err, g.Reg.PC)
-		for depth := 0; ; depth++ {
-			_, file, line, ok := runtime.Caller(depth)
-			if !ok {
-				break
-			}
-			fmt.Fprintf(os.Stderr, "======> %d: %v:%d\n", depth, file, line)
+// RecentInsts returns recently executed instructions in order from oldest
+func (g *GBC) RecentInsts() []CurInst {
+	insts := make([]CurInst, 0, len(g.recent))
+	for i := 0; i < len(g.recent); i++ {
+		inst := g.recent[(g.recentIdx+i)%len(g.recent)]
+		if inst.PC == 0 && inst.Opcode == 0 {
+			continue
 		}
-		os.Exit(1)
+		insts = append(insts, inst)
 	}
+	return insts
 }
 
 func (g *GBC) setModel(m util.GBModel) {
 	g.model = m
 	g.Video.Renderer.Model = m
+	g.Sound.CGB = m >= util.GB_MODEL_CGB
 }
 
 // GBUpdateIRQs
@@ -304,6 +411,9 @@ func (g *GBC) updateIRQs() {
 		return
 	}
 
+	if g.Halt {
+		g.haltExit = true
+	}
 	g.Halt = false
 	if !g.Reg.IME {
 		g.irqPending = 0
@@ -313,7 +423,7 @@ func (g *GBC) updateIRQs() {
 		return
 	}
 
-	for i := 0; i < 4; i++ {
+	for i := 0; i < 5; i++ {
 		if util.Bit(irqs, i) {
 			g.irqPending = i + 1
 			return
@@ -323,16 +433,35 @@ func (g *GBC) updateIRQs() {
 
 func (g *GBC) Draw() []byte { return g.Video.Display().Pix }
 
+// handleJoypad samples host input every frame
 func (g *GBC) handleJoypad() {
-	pressed := g.joypad.Input()
-	if pressed {
-		g.IO[IFIO] = util.SetBit8(g.IO[IFIO], 4, true)
-		g.updateIRQs()
+	if g.joypad.Input() {
+		g.requestJoypadIRQ()
 	}
 }
 
+func (g *GBC) requestJoypadIRQ() {
+	g.IO[IFIO] = util.SetBit8(g.IO[IFIO], 4, true)
+	g.updateIRQs()
+}
+
 func (g *GBC) Frame() int { return g.Video.FrameCounter }
 
+// switchSpeed toggles CGB double speed mode by STOP
+//
+// CPU is stalled for 2050 M-cycles while the clock is switching, DIV is reset.
+func (g *GBC) switchSpeed() {
+	g.DoubleSpeed = !g.DoubleSpeed
+	g.IO[KEY1IO] = byte(util.Bool2Int(g.DoubleSpeed)) << 7
+	g.timer.divReset()
+
+	g.speedSwitch = true
+	g.scheduler.DescheduleEvent(scheduler.SpeedSwitch)
+	g.scheduler.ScheduleEvent(scheduler.SpeedSwitch, func(_ uint64) {
+		g.speedSwitch = false
+	}, 2050*4)
+}
+
 // _GBMemoryDMAService
 func (g *GBC) dmaService(cyclesLate uint64) {
 	remaining := g.dma.remaining
@@ -340,6 +469,7 @@ func (g *GBC) dmaService(cyclesLate uint64) {
 
 	b := g.Load8(g.dma.src)
 	g.Store8(g.dma.dest, b)
+	g.dma.value = b
 
 	g.dma.src++
 	g.dma.dest++
@@ -351,49 +481,88 @@ func (g *GBC) dmaService(cyclesLate uint64) {
 
 // _GBMemoryHDMAService
 func (g *GBC) hdmaService(cyclesLate uint64) {
-	g.cpuBlocked = true
-
-	b := g.Load8(g.hdma.src)
-	g.Store8(g.hdma.dest, b)
-
+	g.Store8(g.hdma.dest, g.Load8(g.hdma.src))
 	g.hdma.src++
-	g.hdma.dest++
+	g.hdma.dest = 0x8000 | ((g.hdma.dest + 1) & 0x1fff)
 	g.hdma.remaining--
-
 	if g.hdma.remaining > 0 {
-		g.scheduler.DescheduleEvent(scheduler.HDMA)
-		g.scheduler.ScheduleEvent(scheduler.HDMA, g.hdmaService, 4-cyclesLate)
+		g.scheduler.ScheduleEvent(scheduler.HDMA, g.hdmaService, 2-cyclesLate)
 		return
 	}
 
-	g.cpuBlocked = false
+	g.hdma.blocks--
 	g.IO[HDMA1IO] = byte(g.hdma.src >> 8)
 	g.IO[HDMA2IO] = byte(g.hdma.src)
 	g.IO[HDMA3IO] = byte(g.hdma.dest >> 8)
 	g.IO[HDMA4IO] = byte(g.hdma.dest)
-	if g.hdma.enable {
-		g.IO[HDMA5IO]--
-		if g.IO[HDMA5IO] == 0xff {
-			g.hdma.enable = false
-		}
-	} else {
+	g.IO[HDMA5IO] = byte(g.hdma.blocks-1) & 0x7f
+
+	switch {
+	case g.hdma.blocks == 0:
+		g.hdma.state = hdmaIdle
 		g.IO[HDMA5IO] = 0xff
+	case !g.hdma.hblank:
+		// general purpose DMA continues until all blocks are copied
+		g.hdma.remaining = 0x10
+		g.scheduler.ScheduleEvent(scheduler.HDMA, g.hdmaService, 2-cyclesLate)
+		return
+	default:
+		g.hdma.state = hdmaWaiting
 	}
+	g.cpuBlocked = false
 }
 
-func (g *GBC) triggerIRQ(idx int) {
-	g.IO[IFIO] = util.SetBit8(g.IO[IFIO], idx, false)
-	g.timer.tick(20)
-	g.pushPC()
-	g.Reg.PC = irqVec[idx]
+// startHDMA starts copying a block, CPU is stalled until the block is copied
+func (g *GBC) startHDMA() {
+	g.hdma.state = hdmaCopying
+	g.hdma.remaining = 0x10
+	g.cpuBlocked = true
+	g.scheduler.DescheduleEvent(scheduler.HDMA)
+	g.scheduler.ScheduleEvent(scheduler.HDMA, g.hdmaService, 2)
+}
+
+// HDMA returns HDMA state
+func (g *GBC) HDMA() HDMAStatus {
+	s := HDMAStatus{
+		State:  "idle",
+		HBlank: g.hdma.hblank,
+		Src:    g.hdma.src,
+		Dest:   g.hdma.dest,
+	}
+	switch g.hdma.state {
+	case hdmaWaiting:
+		s.State, s.Remaining = "waiting", g.hdma.blocks*0x10
+	case hdmaCopying:
+		s.State, s.Remaining = "copying", (g.hdma.blocks-1)*0x10+g.hdma.remaining
+	}
+	return s
+}
+
+// GBCPUIRQ
+//
+// Interrupt vector is decided after pushing PC upper byte, so writing IE by the push can cancel the interrupt and jumps to 0x0000.
+func (g *GBC) triggerIRQ() {
+	g.idleAt(g.Reg.SP)
+	g.push(byte(g.Reg.PC >> 8))
+
+	irqs := g.IO[IEIO] & g.IO[IFIO] & 0x1f
+	g.push(byte(g.Reg.PC))
+	g.idle()
+
+	g.Reg.PC = 0x0000
+	for i := 0; i < 5; i++ {
+		if util.Bit(irqs, i) {
+			g.IO[IFIO] = util.SetBit8(g.IO[IFIO], i, false)
+			g.Reg.PC = irqVec[i]
+			break
+		}
+	}
+	g.timer.tick(g.fixCycles(1))
 }
 
 func (g *GBC) hdmaMode3() {
-	if g.Video.Ly < video.VERTICAL_PIXELS && g.hdma.enable && g.IO[HDMA5IO] != 0xff {
-		g.hdma.remaining = 0x10
-		g.cpuBlocked = true
-		g.scheduler.DescheduleEvent(scheduler.HDMA)
-		g.scheduler.ScheduleEvent(scheduler.HDMA, g.hdmaService, 0)
+	if g.hdma.state == hdmaWaiting && g.hdma.hblank && g.Video.Ly < video.VERTICAL_PIXELS {
+		g.startHDMA()
 	}
 }
 
@@ -40,7 +40,14 @@ const (
 	BCPDIO    byte = 0x69
 	OCPSIO    byte = 0x6a
 	OCPDIO    byte = 0x6b
+	OPRIIO    byte = 0x6c
 	SVBKIO    byte = 0x70
+	UNK72IO   byte = 0x72
+	UNK73IO   byte = 0x73
+	UNK74IO   byte = 0x74
+	UNK75IO   byte = 0x75
+	PCM12IO   byte = 0x76
+	PCM34IO   byte = 0x77
 	IEIO      byte = 0xff
 )
 
@@ -54,11 +61,21 @@ func (g *GBC) resetIO() {
 	g.storeIO(IFIO, 0x01)
 
 	// sound
-	g.IO[0x10], g.IO[0x11], g.IO[0x12], g.IO[0x14] = 0x80, 0xbf, 0xf3, 0xbf // sound1
-	g.IO[0x16], g.IO[0x19] = 0x3f, 0xbf                                     // sound2
-	g.IO[0x1a], g.IO[0x1b], g.IO[0x1c], g.IO[0x1e] = 0x7f, 0xff, 0x9f, 0xbf // sound3
-	g.IO[0x20], g.IO[0x23] = 0xff, 0xbf                                     // sound4
-	g.IO[0x24], g.IO[0x25], g.IO[0x26] = 0x77, 0xf3, 0xf1                   // sound control
+	g.storeIO(0x26, 0x80) // power on first
+	g.storeIO(0x10, 0x80)
+	g.storeIO(0x11, 0xbf)
+	g.storeIO(0x12, 0xf3)
+	g.storeIO(0x14, 0x3f) // boot ROM has triggered channel 1, but it's already silent
+	g.storeIO(0x16, 0x3f)
+	g.storeIO(0x19, 0x3f)
+	g.storeIO(0x1a, 0x7f)
+	g.storeIO(0x1b, 0xff)
+	g.storeIO(0x1c, 0x9f)
+	g.storeIO(0x1e, 0x3f)
+	g.storeIO(0x20, 0xff)
+	g.storeIO(0x23, 0x3f)
+	g.storeIO(0x24, 0x77)
+	g.storeIO(0x25, 0xf3)
 
 	g.storeIO(LCDCIO, 0x91)
 	g.IO[BANKIO] = 0x01
@@ -79,7 +96,6 @@ func (g *GBC) resetIO() {
 	g.storeIO(WXIO, 0x00)
 
 	if model&util.GB_MODEL_CGB != 0 {
-		g.storeIO(KEY0IO, 0x00)
 		g.storeIO(JOYPIO, 0xff)
 		g.storeIO(VBKIO, 0x00)
 		g.storeIO(BCPSIO, 0x80)
@@ -90,6 +106,11 @@ func (g *GBC) resetIO() {
 		g.storeIO(HDMA3IO, 0xff)
 		g.storeIO(HDMA4IO, 0xff)
 		g.IO[HDMA5IO] = 0xff
+		g.IO[OPRIIO] = 0x00 // boot ROM latches CGB object priority, DMG priority is never used because DMG ROM runs on DMG model
+		g.storeIO(UNK72IO, 0x00)
+		g.storeIO(UNK73IO, 0x00)
+		g.storeIO(UNK74IO, 0x00)
+		g.storeIO(UNK75IO, 0x00)
 	}
 
 	g.storeIO(IEIO, 0x00)
@@ -103,8 +124,40 @@ func (g *GBC) loadIO(offset byte) (value byte) {
 		value = g.Video.LCDC
 	case LCDSTATIO:
 		value = g.Video.Stat
+
+	// KEY0 is locked after boot ROM, so it always reads 0xff
+	case KEY0IO:
+		value = 0xff
+
+	// below registers read 0xff on DMG
+	case OPRIIO:
+		value = 0xff
+		if g.model >= util.GB_MODEL_CGB {
+			value = g.IO[OPRIIO] | 0xfe
+		}
+	case UNK72IO, UNK73IO, UNK74IO:
+		value = 0xff
+		if g.model >= util.GB_MODEL_CGB {
+			value = g.IO[offset]
+		}
+	case UNK75IO:
+		value = 0xff
+		if g.model >= util.GB_MODEL_CGB {
+			value = g.IO[UNK75IO] | 0x8f
+		}
+	case PCM12IO:
+		value = 0xff
+		if g.model >= util.GB_MODEL_CGB {
+			value = g.Sound.PCM12()
+		}
+	case PCM34IO:
+		value = 0xff
+		if g.model >= util.GB_MODEL_CGB {
+			value = g.Sound.PCM34()
+		}
+
 	default:
-		if (offset >= 0x10 && offset <= 0x26) || (offset >= 0x30 && offset <= 0x3f) {
+		if offset >= 0x10 && offset <= 0x3f {
 			value = g.Sound.Read(offset)
 		} else {
 			value = g.IO[offset]
@@ -113,11 +166,22 @@ func (g *GBC) loadIO(offset byte) (value byte) {
 	return value
 }
 
+// IOSnapshot returns IO registers(0xff00-0xffff) as CPU reads them
+func (g *GBC) IOSnapshot() []byte {
+	snapshot := make([]byte, 0x100)
+	for i := range snapshot {
+		snapshot[i] = g.loadIO(byte(i))
+	}
+	return snapshot
+}
+
 func (g *GBC) storeIO(offset byte, value byte) {
 	switch offset {
 	case JOYPIO:
 		g.IO[JOYPIO] = value | 0x0f
-		g.joypad.P1 = g.IO[JOYPIO]
+		if g.joypad.Write(g.IO[JOYPIO]) {
+			g.requestJoypadIRQ()
+		}
 		return
 
 	case DIVIO:
@@ -146,10 +210,11 @@ func (g *GBC) storeIO(offset byte, value byte) {
 			base &= 0xdfff
 		}
 		g.scheduler.DescheduleEvent(scheduler.OAMDMA)
-		g.scheduler.ScheduleEvent(scheduler.OAMDMA, g.dmaService, 4>>util.Bool2Int(g.DoubleSpeed)) // 4 * 40 = 160cycle
+		g.scheduler.ScheduleEvent(scheduler.OAMDMA, g.dmaService, 12>>util.Bool2Int(g.DoubleSpeed)) // 1 M-cycle setup, then 4 * 160 = 640cycle
 		g.dma.src = base
 		g.dma.dest = 0xFE00
 		g.dma.remaining = 0xa0
+		g.dma.value = g.Load8(base)
 
 	case LCDCIO:
 		g.Video.ProcessDots(0)
@@ -196,6 +261,17 @@ func (g *GBC) storeIO(offset byte, value byte) {
 		g.Video.ProcessDots(0)
 		g.Video.WritePalette(offset, value)
 
+	case KEY0IO, OPRIIO, PCM12IO, PCM34IO: // KEY0 and OPRI are locked after boot ROM, PCM12/34 are read-only
+		return
+
+	case UNK72IO, UNK73IO, UNK74IO, UNK75IO:
+		if g.model < util.GB_MODEL_CGB {
+			return
+		}
+		if offset == UNK75IO {
+			value &= 0x70
+		}
+
 	case SVBKIO: // switch wram bank
 		bank := value & 0x07
 		if bank == 0 {
@@ -209,12 +285,9 @@ func (g *GBC) storeIO(offset byte, value byte) {
 		return
 
 	default:
-		if offset >= 0x10 && offset <= 0x26 {
+		if offset >= 0x10 && offset <= 0x3f {
 			g.Sound.Write(offset, value)
 		}
-		if offset >= 0x30 && offset <= 0x3f {
-			g.Sound.WriteWaveform(offset, value)
-		}
 	}
 
 	g.IO[offset] = value
@@ -222,26 +295,25 @@ func (g *GBC) storeIO(offset byte, value byte) {
 
 // GBMemoryWriteHDMA5
 func (g *GBC) writeHDMA5(value byte) byte {
+	if g.hdma.state == hdmaWaiting && g.hdma.hblank && !util.Bit(value, 7) {
+		// cancel HBlank DMA, HDMA5 keeps remaining length
+		g.hdma.state = hdmaIdle
+		return 0x80 | byte(g.hdma.blocks-1)
+	}
+
 	g.hdma.src = (uint16(g.IO[HDMA1IO]) << 8) | uint16(g.IO[HDMA2IO])
 	g.hdma.dest = (uint16(g.IO[HDMA3IO]) << 8) | uint16(g.IO[HDMA4IO])
 	g.hdma.src &= 0xfff0
 
 	g.hdma.dest &= 0x1ff0
 	g.hdma.dest |= 0x8000
-	wasHdma := g.hdma.enable
-	g.hdma.enable = util.Bit(value, 7)
+	g.hdma.hblank = util.Bit(value, 7)
+	g.hdma.blocks = int(value&0x7f) + 1
+	g.hdma.state = hdmaWaiting
 
-	if (!wasHdma && !g.hdma.enable) || (util.Bit(g.Video.LCDC, video.Enable) && g.Video.Mode() == 0) {
-		if g.hdma.enable {
-			g.hdma.remaining = 0x10
-		} else {
-			g.hdma.remaining = ((int(value) & 0x7F) + 1) * 0x10
-		}
-		g.cpuBlocked = true
-		g.scheduler.ScheduleEvent(scheduler.HDMA, g.hdmaService, 0)
-	} else if g.hdma.enable && !util.Bit(g.Video.LCDC, video.Enable) {
-		return 0x80 | byte((value+1)&0x7f)
+	// general purpose DMA starts immediately, HBlank DMA also copies the first block if it's in HBlank or LCD is off
+	if !g.hdma.hblank || !util.Bit(g.Video.LCDC, video.Enable) || g.Video.Mode() == 0 {
+		g.startHDMA()
 	}
-
 	return value & 0x7f
 }
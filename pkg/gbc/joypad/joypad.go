@@ -18,11 +18,14 @@ type Joypad struct {
 	P1                byte
 	button, direction [4]bool // start, select, b, a, down, up, left, right
 	handler           [8](func() bool)
+	lines             byte // P10-P13 input lines(low = pressed)
 }
 
 func New(h [8](func() bool)) *Joypad {
 	return &Joypad{
+		P1:      0xff,
 		handler: h,
+		lines:   0x0f,
 	}
 }
 
@@ -31,46 +34,49 @@ func (pad *Joypad) Output() byte {
 	joypad := byte(0x00)
 	if p15 := !util.Bit(pad.P1, 5); p15 {
 		for i := 0; i < 4; i++ {
-			joypad = util.SetBit8(joypad, i, pad.button[i])
+			joypad |= util.Bool2U8(pad.button[i]) << i
 		}
 	}
 	if p14 := !util.Bit(pad.P1, 4); p14 {
 		for i := 0; i < 4; i++ {
-			joypad = util.SetBit8(joypad, i, pad.direction[i])
+			joypad |= util.Bool2U8(pad.direction[i]) << i
 		}
 	}
-	return ^joypad
+	return 0xc0 | (pad.P1 & 0x30) | (^joypad & 0x0f)
 }
 
-// Input joypad
-func (j *Joypad) Input() bool {
-	pressed := false
-
-	// A,B,Start,Select
+// Input samples host input
+//
+// It returns true if a selected input line goes from high to low, it means joypad interrupt is requested.
+func (pad *Joypad) Input() bool {
+	// A,B,Select,Start
 	for i := 0; i < 4; i++ {
-		if j.handler[i]() {
-			old := j.button[i]
-			j.button[i] = true
-			if !old && j.button[i] {
-				pressed = true
-			}
-		} else {
-			j.button[i] = false
-		}
+		pad.button[i] = pad.handler[i]()
 	}
 
 	// Right, Left, Up, Down
 	for i := 0; i < 4; i++ {
-		if j.handler[i+4]() {
-			old := j.direction[i]
-			j.direction[i] = true
-			if !old && j.direction[i] {
-				pressed = true
-			}
-		} else {
-			j.direction[i] = false
-		}
+		pad.direction[i] = pad.handler[i+4]()
 	}
 
-	return pressed
+	return pad.updateLines()
+}
+
+// Write P1(0xff00)
+//
+// Selecting a button group with pressed buttons also makes input lines fall.
+func (pad *Joypad) Write(value byte) bool {
+	pad.P1 = value | 0x0f
+	return pad.updateLines()
+}
+
+// Low reports whether any selected input line is low, this wakes up CPU from STOP mode
+func (pad *Joypad) Low() bool {
+	return pad.lines != 0x0f
+}
+
+func (pad *Joypad) updateLines() bool {
+	old := pad.lines
+	pad.lines = pad.Output() & 0x0f
+	return old&^pad.lines != 0
 }
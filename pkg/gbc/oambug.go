@@ -0,0 +1,69 @@
+package gbc
+
+import "github.com/pokemium/worldwide/pkg/util"
+
+// OAM corruption bug
+//
+// On DMG, if CPU puts FE00-FEFF on the address bus while PPU is in OAM scan (mode 2), the OAM row PPU is reading gets corrupted.
+// 16bit INC/DEC corrupts OAM in the same way as write even if it doesn't access memory.
+//
+// ref: https://gbdev.io/pandocs/OAM_Corruption_Bug.html
+
+const (
+	oamBugWrite = iota
+	oamBugRead
+	oamBugReadIncrease // read and 16bit INC/DEC in the same M-cycle (e.g. LD A, (HL+), POP)
+)
+
+func (g *GBC) oamBug(addr uint16, access int) {
+	if g.model >= util.GB_MODEL_CGB || addr < 0xfe00 || addr >= 0xff00 {
+		return
+	}
+
+	// the first row is not affected
+	row := g.Video.OAMRow()
+	if row < 1 {
+		return
+	}
+
+	switch access {
+	case oamBugWrite:
+		a, b, c := g.oamWord(row, 0), g.oamWord(row-1, 0), g.oamWord(row-1, 2)
+		g.setOAMWord(row, 0, ((a^c)&(b^c))^c)
+		g.copyOAMRow(row, row-1, 1)
+
+	case oamBugReadIncrease:
+		// not in the first 4 rows and the last row
+		if row >= 4 && row < 19 {
+			a, b, c, d := g.oamWord(row-2, 0), g.oamWord(row-1, 0), g.oamWord(row, 0), g.oamWord(row-1, 2)
+			g.setOAMWord(row-1, 0, (b&(a|c|d))|(a&c&d))
+			g.copyOAMRow(row, row-1, 0)
+			g.copyOAMRow(row-2, row-1, 0)
+		}
+		fallthrough // normal read corruption is applied after that
+
+	case oamBugRead:
+		a, b, c := g.oamWord(row, 0), g.oamWord(row-1, 0), g.oamWord(row-1, 2)
+		g.setOAMWord(row, 0, b|(a&c))
+		g.copyOAMRow(row, row-1, 1)
+	}
+}
+
+// oamWord returns i-th word in OAM row
+func (g *GBC) oamWord(row, i int) uint16 {
+	offset := uint16(row*8 + i*2)
+	return uint16(g.Video.Oam.Get(offset)) | uint16(g.Video.Oam.Get(offset+1))<<8
+}
+
+func (g *GBC) setOAMWord(row, i int, value uint16) {
+	offset := uint16(row*8 + i*2)
+	g.Video.Oam.Set(offset, byte(value))
+	g.Video.Oam.Set(offset+1, byte(value>>8))
+}
+
+// copyOAMRow copies words from `from`-th word in src row into dst row
+func (g *GBC) copyOAMRow(dst, src, from int) {
+	for i := from; i < 4; i++ {
+		g.setOAMWord(dst, i, g.oamWord(src, i))
+	}
+}
@@ -1,8 +1,6 @@
 package gbc
 
 import (
-	"fmt"
-
 	"github.com/pokemium/worldwide/pkg/gbc/scheduler"
 	"github.com/pokemium/worldwide/pkg/util"
 )
@@ -11,31 +9,51 @@ func (g *GBC) fixCycles(cycles uint32) uint32 {
 	return cycles * 4 >> util.Bool2U32(g.DoubleSpeed)
 }
 
-func (g *GBC) a16Fetch() uint16 {
-	lower, upper := uint16(g.Load8(g.Inst.PC+1)), uint16(g.Load8(g.Inst.PC+2))
-	g.Reg.PC += 2
-	return (upper << 8) | lower
+// CPU runs in M-cycles, opcode is fetched at the beginning of the first M-cycle and the last M-cycle is finished by `step`.
+// Each of following memory accesses and internal delays finishes the previous M-cycle first,
+// so IO registers are read and written at the same sub-instruction timing as real hardware.
+
+// read8 reads memory in a new M-cycle
+func (g *GBC) read8(addr uint16) byte {
+	g.timer.tick(g.fixCycles(1))
+	g.oamBug(addr, oamBugRead)
+	return g.cpuLoad8(addr)
 }
 
-func (g *GBC) a16FetchJP() uint16 {
-	lower := uint16(g.Load8(g.Inst.PC + 1)) // M = 1: nn read: memory access for low byte
+// write8 writes memory in a new M-cycle
+func (g *GBC) write8(addr uint16, value byte) {
 	g.timer.tick(g.fixCycles(1))
-	upper := uint16(g.Load8(g.Inst.PC + 2)) // M = 2: nn read: memory access for high byte
+	g.oamBug(addr, oamBugWrite)
+	g.cpuStore8(addr, value)
+}
+
+// idle is an M-cycle without memory access (e.g. 16bit ALU, branch)
+func (g *GBC) idle() {
 	g.timer.tick(g.fixCycles(1))
-	g.Reg.PC += 2
-	value := (upper << 8) | lower
-	return value
 }
 
-func (g *GBC) d8Fetch() byte {
-	value := g.Load8(g.Inst.PC + 1)
-	g.Reg.PC++
-	return value
+// idleAt is an M-cycle of 16bit INC/DEC, addr is on the address bus
+func (g *GBC) idleAt(addr uint16) {
+	g.timer.tick(g.fixCycles(1))
+	g.oamBug(addr, oamBugWrite)
 }
 
-func (g *GBC) fetchAtHL() byte {
-	value := g.Load8(g.Reg.HL())
+// read8Inc reads memory in a new M-cycle while the address register is incremented or decremented
+func (g *GBC) read8Inc(addr uint16) byte {
 	g.timer.tick(g.fixCycles(1))
+	g.oamBug(addr, oamBugReadIncrease)
+	return g.cpuLoad8(addr)
+}
+
+func (g *GBC) a16Fetch() uint16 {
+	lower := uint16(g.d8Fetch())
+	upper := uint16(g.d8Fetch())
+	return (upper << 8) | lower
+}
+
+func (g *GBC) d8Fetch() byte {
+	value := g.read8(g.Reg.PC)
+	g.Reg.PC++
 	return value
 }
 
@@ -46,7 +64,11 @@ func ld8r(g *GBC, op1, op2 int) {
 
 // LD r8, mem[r16]
 func ld8m(g *GBC, r8, r16 int) {
-	g.Reg.R[r8] = g.Load8(g.Reg.R16(r16))
+	if r16 == HLI || r16 == HLD {
+		g.Reg.R[r8] = g.read8Inc(g.Reg.R16(r16))
+		return
+	}
+	g.Reg.R[r8] = g.read8(g.Reg.R16(r16))
 }
 
 // LD r8, mem[imm]
@@ -56,38 +78,36 @@ func ld8i(g *GBC, r8, _ int) {
 
 // LD A, (u16)
 func ldau16(g *GBC, operand1, operand2 int) {
-	g.Reg.R[A] = g.Load8(g.a16FetchJP())
+	g.Reg.R[A] = g.read8(g.a16Fetch())
 }
 
 // LD A,(FF00+C)
 func op0xf2(g *GBC, operand1, operand2 int) {
-	addr := 0xff00 + uint16(g.Reg.R[C])
-	g.Reg.R[A] = g.loadIO(byte(addr))
+	g.Reg.R[A] = g.read8(0xff00 + uint16(g.Reg.R[C]))
 }
 
 // LD (HL),u8
 func op0x36(g *GBC, operand1, operand2 int) {
 	value := g.d8Fetch()
-	g.timer.tick(g.fixCycles(1))
-	g.Store8(g.Reg.HL(), value)
+	g.write8(g.Reg.HL(), value)
 }
 
 // LD (HL),R8
 func ldHLR8(g *GBC, unused, op int) {
-	g.Store8(g.Reg.HL(), g.Reg.R[op])
+	g.write8(g.Reg.HL(), g.Reg.R[op])
 }
 
 // LD (u16),SP
 func op0x08(g *GBC, operand1, operand2 int) {
 	addr := g.a16Fetch()
 	upper, lower := byte(g.Reg.SP>>8), byte(g.Reg.SP)
-	g.Store8(addr, lower)
-	g.Store8(addr+1, upper)
+	g.write8(addr, lower)
+	g.write8(addr+1, upper)
 }
 
 // LD (u16),A
 func op0xea(g *GBC, operand1, operand2 int) {
-	g.Store8(g.a16FetchJP(), g.Reg.R[A])
+	g.write8(g.a16Fetch(), g.Reg.R[A])
 }
 
 // LD r16, u16
@@ -98,6 +118,7 @@ func ld16i(g *GBC, r16, _ int) {
 // LD HL,SP+i8
 func op0xf8(g *GBC, operand1, operand2 int) {
 	lhs, rhs := g.Reg.SP, int8(g.d8Fetch())
+	g.idle()
 	value := int32(lhs) + int32(rhs)
 	carryBits := uint32(lhs) ^ uint32(rhs) ^ uint32(value)
 	g.Reg.setHL(uint16(value))
@@ -106,31 +127,27 @@ func op0xf8(g *GBC, operand1, operand2 int) {
 
 // LD SP,HL
 func op0xf9(g *GBC, operand1, operand2 int) {
+	g.idle()
 	g.Reg.SP = g.Reg.HL()
 }
 
 // LD (FF00+C),A
 func op0xe2(g *GBC, operand1, operand2 int) {
-	addr := 0xff00 + uint16(g.Reg.R[C])
-	g.Store8(addr, g.Reg.R[A])
+	g.write8(0xff00+uint16(g.Reg.R[C]), g.Reg.R[A])
 }
 
 func ldm16r(g *GBC, r16, r8 int) {
-	g.Store8(g.Reg.R16(r16), g.Reg.R[r8])
+	g.write8(g.Reg.R16(r16), g.Reg.R[r8])
 }
 
 // LD ($FF00+a8),A
 func op0xe0(g *GBC, operand1, operand2 int) {
-	addr := 0xff00 + uint16(g.d8Fetch())
-	g.timer.tick(g.fixCycles(1))
-	g.storeIO(byte(addr), g.Reg.R[A])
+	g.write8(0xff00+uint16(g.d8Fetch()), g.Reg.R[A])
 }
 
 // LD A,($FF00+a8)
 func op0xf0(g *GBC, operand1, operand2 int) {
-	addr := 0xff00 + uint16(g.d8Fetch())
-	g.timer.tick(g.fixCycles(1))
-	g.Reg.R[A] = g.loadIO(byte(addr))
+	g.Reg.R[A] = g.read8(0xff00 + uint16(g.d8Fetch()))
 }
 
 // nop No operation
@@ -144,14 +161,15 @@ func inc8(g *GBC, r8, _ int) {
 }
 
 func inc16(g *GBC, r16, _ int) {
+	g.idleAt(g.Reg.R16(r16))
 	g.Reg.setR16(r16, g.Reg.R16(r16)+1)
 }
 
 func incHL(g *GBC, _, _ int) {
-	hl := g.fetchAtHL()
+	hl := g.read8(g.Reg.HL())
 	value := hl + 1
 	carryBits := hl ^ 1 ^ value
-	g.Store8(g.Reg.HL(), value)
+	g.write8(g.Reg.HL(), value)
 	g.setZNH(value == 0, false, util.Bit(carryBits, 4))
 }
 
@@ -163,14 +181,15 @@ func dec8(g *GBC, r8, _ int) {
 }
 
 func dec16(g *GBC, r16, _ int) {
+	g.idleAt(g.Reg.R16(r16))
 	g.Reg.setR16(r16, g.Reg.R16(r16)-1)
 }
 
 func decHL(g *GBC, _, _ int) {
-	hl := g.fetchAtHL()
+	hl := g.read8(g.Reg.HL())
 	value := hl - 1
 	carryBits := hl ^ 1 ^ value
-	g.Store8(g.Reg.HL(), value)
+	g.write8(g.Reg.HL(), value)
 	g.setZNH(value == 0, true, util.Bit(carryBits, 4))
 }
 
@@ -182,8 +201,8 @@ func jr(g *GBC, _, _ int) {
 }
 
 func _jr(g *GBC, delta int8) {
+	g.idle()
 	g.Reg.PC = uint16(int32(g.Reg.PC) + int32(delta))
-	g.timer.tick(g.fixCycles(3))
 }
 
 // jr cc,i8
@@ -191,8 +210,6 @@ func jrcc(g *GBC, cc, _ int) {
 	delta := int8(g.d8Fetch())
 	if g.f(cc) {
 		_jr(g, delta)
-	} else {
-		g.timer.tick(g.fixCycles(2))
 	}
 }
 
@@ -201,29 +218,53 @@ func jrncc(g *GBC, cc, _ int) {
 	delta := int8(g.d8Fetch())
 	if !g.f(cc) {
 		_jr(g, delta)
-	} else {
-		g.timer.tick(g.fixCycles(2))
 	}
 }
 
 func halt(g *GBC, _, _ int) {
 	if g.IO[IEIO]&g.IO[IFIO]&0x1f == 0 {
 		g.Halt = true
+		return
+	}
+
+	// HALT bug: if IME=0 and interrupt is pending, CPU doesn't halt and fails to increment PC after reading next opcode
+	if !g.Reg.IME {
+		g.haltBug = true
 	}
 }
 
+// lockup CPU by illegal opcode, only reset can recover it
+func lockup(g *GBC, _, _ int) {
+	g.Lockup = &Lockup{PC: g.Inst.PC, Opcode: g.Inst.Opcode}
+}
+
 // stop GBC
+//
+// https://gbdev.io/pandocs/Reducing_Power_Consumption.html#using-the-stop-instruction
 func stop(g *GBC, _, _ int) {
-	g.Reg.PC++
-	if g.model >= util.GB_MODEL_CGB && util.Bit(g.IO[KEY1IO], 0) {
-		g.DoubleSpeed = !g.DoubleSpeed
-		g.IO[KEY1IO] = byte(util.Bool2Int(g.DoubleSpeed)) << 7
-	} else {
-		sleep := ^(g.loadIO(JOYPIO) & 0x30)
-		if sleep > 0 {
-			fmt.Println("TODO: impl sleep on stop")
+	irqPending := g.IO[IEIO]&g.IO[IFIO]&0x1f != 0
+
+	if g.joypad.Low() {
+		// STOP is ignored while a button is held, this is 1-byte opcode if interrupt is pending, otherwise 2-byte opcode with HALT
+		if !irqPending {
+			g.Reg.PC++
+			g.Halt = true
 		}
+		return
 	}
+
+	if !irqPending {
+		g.Reg.PC++
+	}
+
+	if g.model >= util.GB_MODEL_CGB && util.Bit(g.IO[KEY1IO], 0) {
+		// if IME=1 and interrupt is pending, real CPU glitches non-deterministically, treat it as normal speed switch
+		g.switchSpeed()
+		return
+	}
+
+	g.timer.divReset()
+	g.Stop = true
 }
 
 // XOR xor
@@ -234,7 +275,7 @@ func xor8(g *GBC, _, r8 int) {
 
 // XOR A,(HL)
 func xoraHL(g *GBC, _, _ int) {
-	g.Reg.R[A] ^= g.Load8(g.Reg.HL())
+	g.Reg.R[A] ^= g.read8(g.Reg.HL())
 	g.setZNHC(g.Reg.R[A] == 0, false, false, false)
 }
 
@@ -246,22 +287,24 @@ func xoru8(g *GBC, _, _ int) {
 
 // jp u16
 func jp(g *GBC, _, _ int) {
-	g.Reg.PC = g.a16FetchJP()
+	dst := g.a16Fetch()
+	g.idle()
+	g.Reg.PC = dst
 }
 
 func jpcc(g *GBC, cc, _ int) {
-	dst := g.a16FetchJP()
+	dst := g.a16Fetch()
 	if g.f(cc) {
+		g.idle()
 		g.Reg.PC = dst
-		g.timer.tick(g.fixCycles(1))
 	}
 }
 
 func jpncc(g *GBC, cc, _ int) {
-	dst := g.a16FetchJP()
+	dst := g.a16Fetch()
 	if !g.f(cc) {
+		g.idle()
 		g.Reg.PC = dst
-		g.timer.tick(g.fixCycles(1))
 	}
 }
 
@@ -275,17 +318,17 @@ func ret(g *GBC, _, _ int) {
 }
 
 func retcc(g *GBC, cc, _ int) {
+	g.idle()
 	if g.f(cc) {
 		g.popPC()
-		g.timer.tick(g.fixCycles(3))
 	}
 }
 
 // not retcc
 func retncc(g *GBC, cc, _ int) {
+	g.idle()
 	if !g.f(cc) {
 		g.popPC()
-		g.timer.tick(g.fixCycles(3))
 	}
 }
 
@@ -299,33 +342,28 @@ func reti(g *GBC, operand1, operand2 int) {
 }
 
 func call(g *GBC, _, _ int) {
-	dest := g.a16FetchJP()
+	dest := g.a16Fetch()
 	_call(g, dest)
 }
 
 func _call(g *GBC, dest uint16) {
-	g.timer.tick(g.fixCycles(1))
-	g.pushPCCALL()
-	g.timer.tick(g.fixCycles(1))
+	g.idleAt(g.Reg.SP)
+	g.pushPC()
 	g.Reg.PC = dest
 }
 
 func callcc(g *GBC, cc, _ int) {
-	dest := g.a16FetchJP()
+	dest := g.a16Fetch()
 	if g.f(cc) {
 		_call(g, dest)
-		return
 	}
-	g.timer.tick(g.fixCycles(1))
 }
 
 func callncc(g *GBC, cc, _ int) {
-	dest := g.a16FetchJP()
+	dest := g.a16Fetch()
 	if !g.f(cc) {
 		_call(g, dest)
-		return
 	}
-	g.timer.tick(g.fixCycles(1))
 }
 
 // DI Disable Interrupt
@@ -347,7 +385,7 @@ func cp(g *GBC, _, r8 int) {
 
 // CP A,(HL)
 func cpaHL(g *GBC, _, _ int) {
-	lhs, rhs := g.Reg.R[A], g.Load8(g.Reg.HL())
+	lhs, rhs := g.Reg.R[A], g.read8(g.Reg.HL())
 	value := lhs - rhs
 	carryBits := lhs ^ rhs ^ value
 	g.setZNHC(value == 0, true, util.Bit(carryBits, 4), subC(lhs, rhs))
@@ -369,7 +407,7 @@ func and8(g *GBC, _, r8 int) {
 
 // AND A,(HL)
 func andaHL(g *GBC, _, _ int) {
-	g.Reg.R[A] &= g.Load8(g.Reg.HL())
+	g.Reg.R[A] &= g.read8(g.Reg.HL())
 	g.setZNHC(g.Reg.R[A] == 0, false, true, false)
 }
 
@@ -387,7 +425,7 @@ func or8(g *GBC, _, r8 int) {
 
 // OR A,(HL)
 func oraHL(g *GBC, _, _ int) {
-	g.Reg.R[A] |= g.Load8(g.Reg.HL())
+	g.Reg.R[A] |= g.read8(g.Reg.HL())
 	g.setZNHC(g.Reg.R[A] == 0, false, false, false)
 }
 
@@ -409,6 +447,7 @@ func add8(g *GBC, _, r8 int) {
 // ADD HL,r16
 func addHL(g *GBC, _, r16 int) {
 	lhs, rhs := g.Reg.HL(), g.Reg.R16(r16)
+	g.idle()
 	value := uint32(lhs) + uint32(rhs)
 	carryBits := uint32(lhs) ^ uint32(rhs) ^ value
 	g.Reg.setHL(uint16(value))
@@ -426,7 +465,7 @@ func addu8(g *GBC, _, _ int) {
 
 // ADD A,(HL)
 func addaHL(g *GBC, _, _ int) {
-	lhs, rhs := g.Reg.R[A], g.Load8(g.Reg.HL())
+	lhs, rhs := g.Reg.R[A], g.read8(g.Reg.HL())
 	value := uint16(lhs) + uint16(rhs)
 	carryBits := uint16(lhs) ^ uint16(rhs) ^ value
 	g.Reg.R[A] = byte(value)
@@ -436,6 +475,8 @@ func addaHL(g *GBC, _, _ int) {
 // ADD SP,i8
 func addSPi8(g *GBC, _, _ int) {
 	rhs := int8(g.d8Fetch())
+	g.idle()
+	g.idle()
 	value := int32(g.Reg.SP) + int32(rhs)
 	carryBits := uint32(g.Reg.SP) ^ uint32(rhs) ^ uint32(value)
 	g.Reg.SP = uint16(value)
@@ -450,14 +491,8 @@ func cpl(g *GBC, _, _ int) {
 
 // extend instruction
 func prefixCB(g *GBC, _, _ int) {
-	g.timer.tick(g.fixCycles(1))
 	inst := gbz80instsCb[g.d8Fetch()]
-	op1, op2, cycle, handler := inst.Operand1, inst.Operand2, inst.Cycle1, inst.Handler
-	handler(g, op1, op2)
-
-	if cycle > 1 {
-		g.timer.tick(g.fixCycles(uint32(cycle - 1)))
-	}
+	inst.Handler(g, inst.Operand1, inst.Operand2)
 }
 
 // RLC Rotate n left carry => bit0
@@ -468,10 +503,10 @@ func rlc(g *GBC, r8, _ int) {
 }
 
 func rlcHL(g *GBC, _, _ int) {
-	value := g.fetchAtHL()
+	value := g.read8(g.Reg.HL())
 	bit7 := util.Bit(value, 7)
 	value = util.SetLSB(value<<1, bit7)
-	g.Store8(g.Reg.HL(), value)
+	g.write8(g.Reg.HL(), value)
 	g.setZNHC(value == 0, false, false, bit7)
 }
 
@@ -490,10 +525,10 @@ func rrc(g *GBC, r8, _ int) {
 }
 
 func rrcHL(g *GBC, _, _ int) {
-	value := g.fetchAtHL()
+	value := g.read8(g.Reg.HL())
 	bit0 := util.Bit(value, 0)
 	value = util.SetMSB(value>>1, bit0)
-	g.Store8(g.Reg.HL(), value)
+	g.write8(g.Reg.HL(), value)
 	g.setZNHC(value == 0, false, false, bit0)
 }
 
@@ -512,10 +547,10 @@ func rl(g *GBC, _, r8 int) {
 }
 
 func rlHL(g *GBC, _, _ int) {
-	carry, value := g.f(flagC), g.fetchAtHL()
+	carry, value := g.f(flagC), g.read8(g.Reg.HL())
 	bit7 := util.Bit(value, 7)
 	value = util.SetLSB(value<<1, carry)
-	g.Store8(g.Reg.HL(), value)
+	g.write8(g.Reg.HL(), value)
 	g.setZNHC(value == 0, false, false, bit7)
 }
 
@@ -534,10 +569,10 @@ func rr(g *GBC, r8, _ int) {
 }
 
 func rrHL(g *GBC, _, _ int) {
-	carry, value := g.f(flagC), g.fetchAtHL()
+	carry, value := g.f(flagC), g.read8(g.Reg.HL())
 	bit0 := util.Bit(value, 0)
 	value = util.SetMSB(value>>1, carry)
-	g.Store8(g.Reg.HL(), value)
+	g.write8(g.Reg.HL(), value)
 	g.setZNHC(value == 0, false, false, bit0)
 }
 
@@ -549,10 +584,10 @@ func sla(g *GBC, r8, _ int) {
 }
 
 func slaHL(g *GBC, _, _ int) {
-	value := g.fetchAtHL()
+	value := g.read8(g.Reg.HL())
 	bit7 := util.Bit(value, 7)
 	hl := (value << 1)
-	g.Store8(g.Reg.HL(), hl)
+	g.write8(g.Reg.HL(), hl)
 	g.setZNHC(hl == 0, false, false, bit7)
 }
 
@@ -564,10 +599,10 @@ func sra(g *GBC, r8, _ int) {
 }
 
 func sraHL(g *GBC, operand1, operand2 int) {
-	value := g.fetchAtHL()
+	value := g.read8(g.Reg.HL())
 	bit0, bit7 := util.Bit(value, 0), util.Bit(value, 7)
 	value = util.SetMSB(value>>1, bit7)
-	g.Store8(g.Reg.HL(), value)
+	g.write8(g.Reg.HL(), value)
 	g.setZNHC(value == 0, false, false, bit0)
 }
 
@@ -580,10 +615,10 @@ func swap(g *GBC, _, r8 int) {
 }
 
 func swapHL(g *GBC, _, _ int) {
-	b := g.fetchAtHL()
+	b := g.read8(g.Reg.HL())
 	upper, lower := b>>4, b&0b1111
 	value := (lower << 4) | upper
-	g.Store8(g.Reg.HL(), value)
+	g.write8(g.Reg.HL(), value)
 	g.setZNHC(value == 0, false, false, false)
 }
 
@@ -595,10 +630,10 @@ func srl(g *GBC, r8, _ int) {
 }
 
 func srlHL(g *GBC, _, _ int) {
-	value := g.fetchAtHL()
+	value := g.read8(g.Reg.HL())
 	bit0 := util.Bit(value, 0)
 	value = (value >> 1)
-	g.Store8(g.Reg.HL(), value)
+	g.write8(g.Reg.HL(), value)
 	g.setZNHC(value == 0, false, false, bit0)
 }
 
@@ -609,7 +644,7 @@ func bit(g *GBC, bit, r8 int) {
 }
 
 func bitHL(g *GBC, bit, _ int) {
-	isSet := util.Bit(g.Load8(g.Reg.HL()), bit)
+	isSet := util.Bit(g.read8(g.Reg.HL()), bit)
 	g.setZNH(!isSet, false, true)
 }
 
@@ -619,7 +654,7 @@ func res(g *GBC, bit, r8 int) {
 
 func resHL(g *GBC, bit, _ int) {
 	mask := ^(byte(1) << bit)
-	g.Store8(g.Reg.HL(), g.fetchAtHL()&mask)
+	g.write8(g.Reg.HL(), g.read8(g.Reg.HL())&mask)
 }
 
 func set(g *GBC, bit, r8 int) {
@@ -628,35 +663,30 @@ func set(g *GBC, bit, r8 int) {
 
 func setHL(g *GBC, bit, _ int) {
 	mask := byte(1) << bit
-	g.Store8(g.Reg.HL(), g.fetchAtHL()|mask)
+	g.write8(g.Reg.HL(), g.read8(g.Reg.HL())|mask)
 }
 
 // push af
 func pushAF(g *GBC, _, _ int) {
-	g.timer.tick(g.fixCycles(1))
+	g.idleAt(g.Reg.SP)
 	g.push(g.Reg.R[A])
-	g.timer.tick(g.fixCycles(1))
 	g.push(g.Reg.R[F] & 0xf0)
 }
 
 // push r16
 func push(g *GBC, r0, r1 int) {
-	g.timer.tick(g.fixCycles(1))
+	g.idleAt(g.Reg.SP)
 	g.push(g.Reg.R[r0])
-	g.timer.tick(g.fixCycles(1))
 	g.push(g.Reg.R[r1])
 }
 
 func popAF(g *GBC, _, _ int) {
-	g.Reg.R[F] = g.pop() & 0xf0
-	g.timer.tick(g.fixCycles(1))
-	g.Reg.R[A] = g.pop()
+	f, a := g.pop16()
+	g.Reg.R[F], g.Reg.R[A] = f&0xf0, a
 }
 
 func pop(g *GBC, r0, r1 int) {
-	g.Reg.R[r0] = g.pop()
-	g.timer.tick(g.fixCycles(1))
-	g.Reg.R[r1] = g.pop()
+	g.Reg.R[r0], g.Reg.R[r1] = g.pop16()
 }
 
 // SUB subtract
@@ -670,7 +700,7 @@ func sub8(g *GBC, _, r8 int) {
 
 // SUB A,(HL)
 func subaHL(g *GBC, _, _ int) {
-	lhs, rhs := g.Reg.R[A], g.Load8(g.Reg.HL())
+	lhs, rhs := g.Reg.R[A], g.read8(g.Reg.HL())
 	value := lhs - rhs
 	carryBits := lhs ^ rhs ^ value
 	g.Reg.R[A] = value
@@ -711,7 +741,7 @@ func adc8(g *GBC, _, op int) {
 
 // ADC A,(HL)
 func adcaHL(g *GBC, _, _ int) {
-	lhs, rhs, carry := g.Load8(g.Reg.HL()), g.Reg.R[A], util.Bool2U8(g.f(flagC))
+	lhs, rhs, carry := g.read8(g.Reg.HL()), g.Reg.R[A], util.Bool2U8(g.f(flagC))
 	value := lhs + carry + rhs
 	value4, value16 := (lhs&0x0f)+carry+(rhs&0b1111), uint16(lhs)+uint16(rhs)+uint16(carry)
 	g.Reg.R[A] = value
@@ -739,7 +769,7 @@ func sbc8(g *GBC, _, op int) {
 
 // SBC A,(HL)
 func sbcaHL(g *GBC, _, _ int) {
-	lhs, rhs, carry := g.Reg.R[A], g.Load8(g.Reg.HL()), util.Bool2U8(g.f(flagC))
+	lhs, rhs, carry := g.Reg.R[A], g.read8(g.Reg.HL()), util.Bool2U8(g.f(flagC))
 	value := lhs - (rhs + carry)
 	value4, value16 := (lhs&0b1111)-((rhs&0x0f)+carry), uint16(lhs)-(uint16(rhs)+uint16(carry))
 	g.Reg.R[A] = value
@@ -776,6 +806,7 @@ func daa(g *GBC, _, _ int) {
 
 // push present address and jump to vector address
 func rst(g *GBC, addr, _ int) {
+	g.idleAt(g.Reg.SP)
 	g.pushPC()
 	g.Reg.PC = uint16(addr)
 }
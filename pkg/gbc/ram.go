@@ -2,6 +2,8 @@ package gbc
 
 import (
 	"github.com/pokemium/worldwide/pkg/gbc/cart"
+	"github.com/pokemium/worldwide/pkg/gbc/video"
+	"github.com/pokemium/worldwide/pkg/util"
 )
 
 // Load8 fetch value from ram
@@ -33,6 +35,9 @@ func (g *GBC) Load8(addr uint16) (value byte) {
 	case addr >= 0xd000 && addr < 0xe000:
 		// WRAM bank1..7
 		value = g.WRAM.buffer[g.WRAM.bank][addr-0xd000]
+	case addr >= 0xe000 && addr < 0xfe00:
+		// echo RAM
+		value = g.Load8(addr - 0x2000)
 
 	case addr >= 0xfe00 && addr < 0xfea0:
 		// OAM
@@ -70,6 +75,9 @@ func (g *GBC) Store8(addr uint16, value byte) {
 	case addr >= 0xd000 && addr < 0xe000:
 		// WRAM bank1 or 7
 		g.WRAM.buffer[g.WRAM.bank][addr-0xd000] = value
+	case addr >= 0xe000 && addr < 0xfe00:
+		// echo RAM
+		g.Store8(addr-0x2000, value)
 
 	case addr >= 0xfe00 && addr <= 0xfe9f:
 		// OAM
@@ -81,6 +89,114 @@ func (g *GBC) Store8(addr uint16, value byte) {
 	}
 }
 
+// cpuLoad8 is Load8 from CPU, blocked memory reads 0xff
+//
+// On OAM DMA bus conflict, CPU reads the byte OAM DMA is transferring.
+func (g *GBC) cpuLoad8(addr uint16) byte {
+	if g.dmaConflict(addr) {
+		g.violate(addr, false)
+		return g.dma.value
+	}
+	if g.blocked(addr) {
+		g.violate(addr, false)
+		return 0xff
+	}
+	return g.Load8(addr)
+}
+
+// cpuStore8 is Store8 from CPU, blocked memory ignores writes
+func (g *GBC) cpuStore8(addr uint16, value byte) {
+	if g.blocked(addr) {
+		g.violate(addr, true)
+		if !isPaletteData(addr) {
+			return
+		}
+		// palette data is not written in mode 3, but palette index is still incremented
+	}
+	g.Store8(addr, value)
+}
+
+// blocked reports whether CPU can't access addr because PPU or OAM DMA is using it
+//
+// ref: https://gbdev.io/pandocs/Accessing_VRAM_and_OAM.html, https://gbdev.io/pandocs/OAM_DMA_Transfer.html
+func (g *GBC) blocked(addr uint16) bool {
+	if g.dma.remaining > 0 {
+		// OAM DMA occupies OAM and the bus its source is on, so CPU should run in HRAM
+		if addr >= 0xfe00 && addr < 0xff00 {
+			return true
+		}
+		if g.dmaConflict(addr) {
+			return true
+		}
+	}
+
+	if !util.Bit(g.Video.LCDC, video.Enable) {
+		return false
+	}
+	mode := g.Video.Mode()
+	switch {
+	case isVRAM(addr):
+		return mode == 3
+	case addr >= 0xfe00 && addr < 0xff00:
+		// OAM scan and pixel transfer
+		return mode == 2 || mode == 3
+	case isPaletteData(addr):
+		return g.model >= util.GB_MODEL_CGB && mode == 3
+	}
+	return false
+}
+
+func (g *GBC) violate(addr uint16, write bool) {
+	if g.OnViolation == nil {
+		return
+	}
+	g.OnViolation(Violation{
+		PC:    g.Inst.PC,
+		Addr:  addr,
+		Write: write,
+		Mode:  g.Video.Mode(),
+		Ly:    g.Video.Ly,
+		DMA:   g.dma.remaining > 0,
+	})
+}
+
+func isVRAM(addr uint16) bool { return addr >= 0x8000 && addr < 0xa000 }
+
+type bus int
+
+const (
+	busNone     bus = iota // OAM, IO, HRAM
+	busExternal            // ROM, SRAM (and WRAM on DMG)
+	busVRAM
+	busWRAM // CGB only
+)
+
+// busOf returns memory bus of addr, CGB has WRAM on a separate bus from cartridge
+func (g *GBC) busOf(addr uint16) bus {
+	switch {
+	case addr >= 0xfe00:
+		return busNone
+	case isVRAM(addr):
+		return busVRAM
+	case addr >= 0xc000 && g.model >= util.GB_MODEL_CGB:
+		return busWRAM
+	}
+	return busExternal
+}
+
+// dmaConflict reports whether CPU access to addr conflicts with OAM DMA on the same bus
+func (g *GBC) dmaConflict(addr uint16) bool {
+	if g.dma.remaining == 0 {
+		return false
+	}
+	b := g.busOf(addr)
+	return b != busNone && b == g.busOf(g.dma.src)
+}
+
+func isPaletteData(addr uint16) bool {
+	return addr == 0xff00|uint16(BCPDIO) || addr == 0xff00|uint16(OCPDIO)
+}
+
 func (g *GBC) mbcWrite(addr uint16, value byte) {
 	if (addr >= 0x2000) && (addr <= 0x3fff) {
 		switch g.Cartridge.MBC {
@@ -1,5 +1,7 @@
 package gbc
 
+import "fmt"
+
 const (
 	A = iota
 	B
@@ -31,6 +33,7 @@ type Register struct {
 	SP  uint16
 	PC  uint16
 	IME bool
+	err error // error occurred while accessing registers, this is reported by Step
 }
 
 func (r *Register) R16(i int) uint16 {
@@ -56,7 +59,8 @@ func (r *Register) R16(i int) uint16 {
 	case PC:
 		return r.PC
 	}
-	panic("invalid register16")
+	r.err = fmt.Errorf("invalid register16: %d", i)
+	return 0
 }
 
 func (r *Register) setR16(i int, val uint16) {
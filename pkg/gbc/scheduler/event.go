@@ -13,6 +13,7 @@ const (
 	EndMode3    EventName = "EndMode3"
 	UpdateFrame EventName = "UpdateFrame"
 	EiPending   EventName = "EiPending"
+	SpeedSwitch EventName = "SpeedSwitch"
 )
 
 type Event struct {
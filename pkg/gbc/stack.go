@@ -1,16 +1,17 @@
 package gbc
 
-import "github.com/pokemium/worldwide/pkg/util"
-
 func (g *GBC) push(b byte) {
-	g.Store8(g.Reg.SP-1, b)
 	g.Reg.SP--
+	g.write8(g.Reg.SP, b)
 }
 
-func (g *GBC) pop() byte {
-	value := g.Load8(g.Reg.SP)
+// pop16 pops lower byte and upper byte, SP is incremented while the first byte is read
+func (g *GBC) pop16() (lower, upper byte) {
+	lower = g.read8Inc(g.Reg.SP)
+	g.Reg.SP++
+	upper = g.read8(g.Reg.SP)
 	g.Reg.SP++
-	return value
+	return lower, upper
 }
 
 func (g *GBC) pushPC() {
@@ -19,16 +20,8 @@ func (g *GBC) pushPC() {
 	g.push(lower)
 }
 
-func (g *GBC) pushPCCALL() {
-	upper, lower := byte(g.Reg.PC>>8), byte(g.Reg.PC&0x00ff)
-	g.push(upper)
-	g.timer.tick(1 * 4 >> util.Bool2U32(g.DoubleSpeed)) // M = 4: PC push: memory access for high byte
-	g.push(lower)
-	g.timer.tick(1 * 4 >> util.Bool2U32(g.DoubleSpeed)) // M = 5: PC push: memory access for low byte
-}
-
 func (g *GBC) popPC() {
-	lower := uint16(g.pop())
-	upper := uint16(g.pop())
-	g.Reg.PC = (upper << 8) | lower
+	lower, upper := g.pop16()
+	g.idle()
+	g.Reg.PC = (uint16(upper) << 8) | uint16(lower)
 }
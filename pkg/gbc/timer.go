@@ -34,7 +34,7 @@ func (t *Timer) reset() {
 
 // mTimingTick
 func (t *Timer) tick(cycles uint32) {
-	t.p.Sound.Buffer(int(cycles))
+	t.p.Sound.Tick(cycles)
 	t.p.scheduler.Add(uint64(cycles))
 	for {
 		if t.p.scheduler.Next() > t.p.scheduler.Cycle() {
@@ -69,11 +69,21 @@ func (t *Timer) internalDivIncrement() {
 			}
 		}
 
+		if t.internalDiv&t.apuMask() == t.apuMask() {
+			t.p.Sound.FrameSequencer()
+		}
 		t.internalDiv++
 		t.p.IO[DIVIO] = byte(t.internalDiv >> 4)
 	}
 }
 
+// apuMask is internal div bits which are all set just before APU frame sequencer is clocked
+//
+// frame sequencer is clocked by falling edge of DIV bit 4 (bit 5 on double speed), it's 512Hz
+func (t *Timer) apuMask() uint32 {
+	return 0x3ff >> util.Bool2U32(!t.p.DoubleSpeed)
+}
+
 // _GBTimerUpdate (system count)
 // 1/16384sec(256cycles) or 1/32768sec(128cycles)
 func (t *Timer) update(cyclesLate uint64) {
@@ -101,6 +111,10 @@ func (t *Timer) divReset() {
 	t.p.scheduler.DescheduleEvent(scheduler.TimerUpdate)
 	t.internalDivIncrement()
 
+	// resetting DIV also makes falling edge for APU frame sequencer
+	if t.internalDiv&((t.apuMask()+1)>>1) != 0 {
+		t.p.Sound.FrameSequencer()
+	}
 	t.p.IO[DIVIO] = 0
 	t.internalDiv = 0
 	t.nextDiv = GB_DMG_DIV_PERIOD >> util.Bool2U32(t.p.DoubleSpeed) // 16 or 8 -> 1/16384 sec or 1/32768 sec
@@ -0,0 +1,378 @@
+package video
+
+import (
+	"github.com/pokemium/worldwide/pkg/util"
+)
+
+// Pixel FIFO
+//
+// Mode 3 is emulated dot by dot. The background fetcher pushes a row of 8 pixels into BG FIFO when it is empty,
+// and one pixel is shifted out to LCD every dot. OBJ fetch, SCX fine scroll and window stall the FIFO, so mode 3 length is variable.
+//
+// ref: https://gbdev.io/pandocs/pixel_fifo.html
+
+const (
+	fetchDummy     = -6 // first tile fetch on each line is thrown away
+	fetchPush      = 6  // tile number, tile data low and tile data high take 2 dots each
+	objFetchLength = 6
+)
+
+type objPixel struct {
+	value uint16 // palette | color
+	prio  bool   // BG and window colors 1-3 over OBJ
+	index int8   // OAM index (CGB priority)
+}
+
+type pixelFIFO struct {
+	y, x, dot int
+	discard   int // pixels thrown away by SCX fine scroll (or window WX < 7)
+
+	bg            [8]uint16 // palette | color | OBJ_PRIORITY
+	bgHead, bgLen int
+	obj           [8]objPixel // obj[0] is mixed with next pixel
+
+	// background fetcher
+	step              int
+	tileX             int
+	tile, attr        byte
+	tileLow, tileHigh byte
+	window            bool
+
+	objWait int // index of OBJ waiting for fetch, -1 if none
+	objDots int // remaining dots of OBJ fetch
+	fetched [MAX_LINE_OBJ]bool
+}
+
+// startFrame resets window internal line counter
+func (r *Renderer) startFrame() {
+	r.windowLine, r.wyTriggered = 0, false
+}
+
+// startLine is called at the beginning of mode 3
+func (r *Renderer) startLine(y int) {
+	r.fifo = pixelFIFO{
+		y:       y,
+		discard: int(r.g.io[GB_REG_SCX]) & 7,
+		step:    fetchDummy,
+		objWait: -1,
+	}
+	if byte(y) == r.wy {
+		r.wyTriggered = true
+	}
+	r.cleanOAM(y)
+
+	highlightAmount := (r.highlightAmount + 6) >> 4
+	if r.lastHighlightAmount != highlightAmount {
+		r.lastHighlightAmount = highlightAmount
+		for i := 0; i < PAL_SGB_BORDER; i++ {
+			if i >= PAL_OBJ && i&3 == 0 {
+				continue
+			}
+			r.Palette[i+PAL_HIGHLIGHT] = r.Palette[i]
+		}
+	}
+}
+
+// drawDots runs pixel FIFO until `dots` dots have passed since mode 3 started, and returns the number of pixels drawn
+func (r *Renderer) drawDots(dots int, skip bool) int {
+	for r.fifo.dot < dots && r.fifo.x < HORIZONTAL_PIXELS {
+		r.tick(skip)
+	}
+	return r.fifo.x
+}
+
+// tick 1 dot
+func (r *Renderer) tick(skip bool) {
+	f := &r.fifo
+	f.dot++
+
+	if f.objDots > 0 {
+		f.objDots--
+		if f.objDots == 0 {
+			r.fetchObj(f.objWait)
+			f.objWait = -1
+		}
+		return
+	}
+
+	if f.objWait < 0 && f.discard == 0 {
+		f.objWait = r.findObj()
+	}
+	if f.objWait >= 0 {
+		// OBJ fetch waits until background fetcher has fetched tile data
+		if f.bgLen == 0 || f.step < 4 {
+			r.fetchBG()
+		} else {
+			f.objDots = objFetchLength - 1
+		}
+		return
+	}
+
+	r.fetchBG()
+	if f.bgLen == 0 {
+		return
+	}
+
+	if f.discard == 0 && !f.window && r.windowTriggered() {
+		// window restarts background fetcher
+		f.window, f.tileX, f.bgLen, f.step = true, 0, 0, 1
+		if r.wx < 7 {
+			f.discard = 7 - int(r.wx)
+		}
+		return
+	}
+
+	bg := f.bg[f.bgHead]
+	f.bgHead++
+	f.bgLen--
+	if f.discard > 0 {
+		f.discard--
+		return
+	}
+
+	obj := f.obj[0]
+	copy(f.obj[:], f.obj[1:])
+	f.obj[7] = objPixel{}
+
+	if !skip {
+		r.drawPixel(f.x, r.mix(bg, obj))
+	}
+	f.x++
+}
+
+// fetchBG steps background fetcher
+func (r *Renderer) fetchBG() {
+	f := &r.fifo
+	if f.step == fetchPush {
+		if f.bgLen == 0 {
+			r.pushBG()
+			f.step = 0
+			f.tileX++
+		}
+		return
+	}
+
+	f.step++
+	switch f.step {
+	case 2:
+		mapIdx, mapX, mapY := GB_BASE_MAP, 0, 0
+		if f.window {
+			if util.Bit(r.g.LCDC, WindowTileMap) {
+				mapIdx += GB_SIZE_MAP
+			}
+			mapX, mapY = f.tileX&0x1f, r.windowLine>>3
+		} else {
+			if util.Bit(r.g.LCDC, TileMap) {
+				mapIdx += GB_SIZE_MAP
+			}
+			mapX, mapY = (int(r.g.io[GB_REG_SCX])>>3+f.tileX)&0x1f, ((f.y+int(r.g.io[GB_REG_SCY]))&0xff)>>3
+		}
+		f.tile = r.g.VRAM.Buffer[mapIdx+mapY*0x20+mapX]
+		f.attr = 0
+		if r.Model >= util.GB_MODEL_CGB {
+			f.attr = r.g.VRAM.Buffer[mapIdx+GB_SIZE_VRAM_BANK0+mapY*0x20+mapX]
+		}
+	case 4:
+		f.tileLow = r.g.VRAM.Buffer[r.tileDataAddr()]
+	case 6:
+		f.tileHigh = r.g.VRAM.Buffer[r.tileDataAddr()+1]
+	}
+}
+
+// tileDataAddr returns VRAM index of current BG tile row
+func (r *Renderer) tileDataAddr() int {
+	f := &r.fifo
+	localY := (f.y + int(r.g.io[GB_REG_SCY])) & 7
+	if f.window {
+		localY = r.windowLine & 7
+	}
+	if util.Bit(f.attr, ObjAttrYFlip) {
+		localY = 7 - localY
+	}
+
+	addr := 0x1000 + int(int8(f.tile))*16 // 0x8800-0x97ff [-128, 127]
+	if util.Bit(r.g.LCDC, TileData) {
+		addr = int(f.tile) * 16 // 0x8000-0x8fff [0, 255]
+	}
+	if util.Bit(f.attr, ObjAttrBank) {
+		addr += GB_SIZE_VRAM_BANK0
+	}
+	return addr + localY*2
+}
+
+func (r *Renderer) pushBG() {
+	f := &r.fifo
+	p := uint16(PAL_BG)
+	if (f.window && r.highlightWIN) || (!f.window && r.highlightBG) {
+		p = PAL_HIGHLIGHT_BG
+	}
+	if r.Model >= util.GB_MODEL_CGB {
+		p |= uint16(f.attr&0x7) * 4
+		if util.Bit(f.attr, ObjAttrPriority) {
+			p |= OBJ_PRIORITY
+		}
+	}
+
+	for i := 0; i < 8; i++ {
+		b := 7 - i
+		if util.Bit(f.attr, ObjAttrXFlip) {
+			b = i
+		}
+		color := uint16((f.tileHigh>>b)&1)<<1 | uint16((f.tileLow>>b)&1)
+		if !f.window && r.disableBG {
+			color = 0
+		}
+		f.bg[i] = p | color
+	}
+	f.bgHead, f.bgLen = 0, 8
+}
+
+// windowTriggered reports whether window starts at current pixel
+func (r *Renderer) windowTriggered() bool {
+	if !util.Bit(r.g.LCDC, Window) || r.disableWIN || !r.wyTriggered {
+		return false
+	}
+	if r.wx < 7 {
+		return r.fifo.x == 0
+	}
+	return int(r.wx)-7 == r.fifo.x
+}
+
+// findObj returns OBJ which starts at current pixel, -1 if none
+func (r *Renderer) findObj() int {
+	if !util.Bit(r.g.LCDC, ObjEnable) || r.disableOBJ {
+		return -1
+	}
+
+	f := &r.fifo
+	for i := 0; i < r.objMax; i++ {
+		if f.fetched[i] || r.obj[i].obj.x == 0 {
+			continue
+		}
+		x := int(r.obj[i].obj.x) - 8
+		if x == f.x || (f.x == 0 && x < 0) {
+			return i
+		}
+	}
+	return -1
+}
+
+// fetchObj loads OBJ row into OBJ FIFO
+func (r *Renderer) fetchObj(i int) {
+	f := &r.fifo
+	f.fetched[i] = true
+	obj, y := r.obj[i], f.y
+
+	vramIdx := 0x0
+	tileOffset, bottomY := 0, 0
+	objY := int(obj.obj.y)
+	if util.Bit(obj.obj.attr, ObjAttrYFlip) {
+		bottomY = 7 - ((y - objY - 16) & 7)
+		if util.Bit(r.g.LCDC, ObjSize) && y-objY < -8 {
+			tileOffset++
+		}
+	} else {
+		bottomY = (y - objY - 16) & 7
+		if util.Bit(r.g.LCDC, ObjSize) && y-objY >= -8 {
+			tileOffset++
+		}
+	}
+	if util.Bit(r.g.LCDC, ObjSize) && obj.obj.tile&1 == 1 {
+		tileOffset--
+	}
+
+	p := uint16(PAL_OBJ)
+	if r.highlightOBJ[obj.index] {
+		p = PAL_HIGHLIGHT_OBJ
+	}
+	if r.Model >= util.GB_MODEL_CGB {
+		p |= uint16(obj.obj.attr&0x07) * 4
+		if util.Bit(obj.obj.attr, ObjAttrBank) {
+			vramIdx += GB_SIZE_VRAM_BANK0
+		}
+	} else {
+		p |= (uint16((obj.obj.attr>>ObjAttrPalette)&1) + 8) * 4 // 8x4 or 9x4
+	}
+
+	objTile := int(obj.obj.tile) + tileOffset
+	tileDataLower := r.g.VRAM.Buffer[vramIdx+(objTile*8+bottomY)*2]
+	tileDataUpper := r.g.VRAM.Buffer[vramIdx+(objTile*8+bottomY)*2+1]
+
+	objX := int(obj.obj.x) - 8
+	for col := 0; col < 8; col++ {
+		slot := objX + col - f.x
+		if slot < 0 || slot >= 8 {
+			continue
+		}
+
+		b := 7 - col
+		if util.Bit(obj.obj.attr, ObjAttrXFlip) {
+			b = col
+		}
+		color := uint16((tileDataUpper>>b)&1)<<1 | uint16((tileDataLower>>b)&1)
+		if color == 0 {
+			continue
+		}
+
+		// DMG: OBJ fetched earlier (smaller X) wins, CGB: smaller OAM index wins
+		current := f.obj[slot]
+		if current.value&3 != 0 && (r.Model < util.GB_MODEL_CGB || current.index < obj.index) {
+			continue
+		}
+		f.obj[slot] = objPixel{
+			value: p | color,
+			prio:  util.Bit(obj.obj.attr, ObjAttrPriority),
+			index: obj.index,
+		}
+	}
+}
+
+// mix BG pixel and OBJ pixel
+func (r *Renderer) mix(bg uint16, obj objPixel) uint16 {
+	bgEnable := util.Bit(r.g.LCDC, BgEnable)
+	if r.Model < util.GB_MODEL_CGB && !bgEnable {
+		// DMG: BG and window become blank
+		bg = 0
+	}
+
+	if obj.value&3 == 0 || !util.Bit(r.g.LCDC, ObjEnable) {
+		return bg
+	}
+	if bg&3 != 0 {
+		if r.Model >= util.GB_MODEL_CGB {
+			// CGB: LCDC.0 clear means OBJ always has priority
+			if bgEnable && (bg&OBJ_PRIORITY != 0 || obj.prio) {
+				return bg
+			}
+		} else if obj.prio {
+			return bg
+		}
+	}
+	return obj.value
+}
+
+// drawPixel writes pixel into output buffer
+func (r *Renderer) drawPixel(x int, value uint16) {
+	y := r.fifo.y
+	sgbOffset := 0
+	if (r.Model&util.GB_MODEL_SGB != 0) && r.sgbBorders {
+		sgbOffset = r.outputBufferStride*40 + 48
+	}
+	row := r.outputBuffer[r.outputBufferStride*y+sgbOffset:]
+
+	switch r.sgbRenderMode {
+	case 0:
+		p := 0
+		if r.Model&util.GB_MODEL_SGB != 0 {
+			p = int(r.sgbAttributes[(x>>5)+5*(y>>3)])
+			p >>= 6 - ((x / 4) & 0x6)
+			p &= 3
+			p <<= 2
+		}
+		row[x] = r.Palette[p|int(r.Lookup[value&OBJ_PRIO_MASK])]
+	case 2:
+		row[x] = 0
+	case 3:
+		row[x] = r.Palette[0]
+	}
+}
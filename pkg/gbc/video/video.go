@@ -62,6 +62,7 @@ type Video struct {
 
 	scheduler *scheduler.Scheduler
 	hdma      func()
+	dotClock  uint64 // cycle when mode 3 started
 }
 
 var (
@@ -140,7 +141,7 @@ func (g *Video) SkipBIOS() {
 
 	g.io[GB_REG_IF] = util.SetBit8(g.io[GB_REG_IF], 0, true)
 	g.updateIRQs()
-	g.scheduler.DescheduleEvent(scheduler.EndMode1)
+	g.descheduleModeEvents()
 	g.scheduler.ScheduleEvent(scheduler.EndMode1, g.EndMode1, next)
 }
 
@@ -245,23 +246,28 @@ func (g *Video) SwitchBank(value byte) {
 	g.VRAM.Bank = uint16(value)
 }
 
+// SetFrameskip skips rendering n frames out of every n+1 frames
+func (g *Video) SetFrameskip(n int) {
+	g.frameskip = n
+	if g.frameskipCounter > n {
+		g.frameskipCounter = n
+	}
+}
+
 // GBVideoProcessDots
 func (g *Video) ProcessDots(cyclesLate uint64) {
 	if g.Mode() != 3 {
 		return
 	}
 
-	oldX := 0
-	g.X = HORIZONTAL_PIXELS
-	g.Renderer.drawRange(oldX, g.X, g.Ly)
+	dots := int(g.scheduler.Cycle() - cyclesLate - g.dotClock)
+	g.X = g.Renderer.drawDots(dots, g.frameskipCounter > 0)
 }
 
 // mode0 = HBlank
 // 204 cycles
 func (g *Video) EndMode0(cyclesLate uint64) {
-	if g.frameskipCounter <= 0 {
-		g.Renderer.finishScanline(g.Ly)
-	}
+	g.Renderer.finishScanline(g.Ly)
 
 	lyc := g.io[GB_REG_LYC]
 	g.Ly++
@@ -310,6 +316,7 @@ func (g *Video) EndMode1(cyclesLate uint64) {
 	switch g.Ly {
 	case VERTICAL_TOTAL_PIXELS + 1:
 		g.Ly, g.io[GB_REG_LY] = 0, 0
+		g.Renderer.startFrame()
 		g.setMode(2)
 		defer g.scheduler.ScheduleEvent(scheduler.EndMode2, g.EndMode2, MODE_2_LENGTH-cyclesLate)
 	case VERTICAL_TOTAL_PIXELS:
@@ -335,9 +342,11 @@ func (g *Video) EndMode1(cyclesLate uint64) {
 // 80 cycles
 func (g *Video) EndMode2(cyclesLate uint64) {
 	oldStat := g.Stat
-	g.X = -(int(g.io[GB_REG_SCX]) & 7)
+	g.dotClock = g.scheduler.Cycle() - cyclesLate
+	g.X = 0
+	g.Renderer.startLine(g.Ly)
 	g.setMode(3)
-	g.scheduler.ScheduleEvent(scheduler.EndMode3, g.EndMode3, MODE_3_LENGTH+uint64(g.Renderer.objMax*6)-cyclesLate)
+	g.scheduler.ScheduleEvent(scheduler.EndMode3, g.EndMode3, MODE_3_LENGTH-cyclesLate)
 	if !statIRQAsserted(oldStat) && statIRQAsserted(g.Stat) {
 		g.io[GB_REG_IF] = util.SetBit8(g.io[GB_REG_IF], 1, true)
 		g.updateIRQs()
@@ -345,13 +354,22 @@ func (g *Video) EndMode2(cyclesLate uint64) {
 }
 
 // mode3 = [mode0 -> mode2 -> mode3] -> [mode0 -> mode2 -> mode3] -> ...
-// 172 cycles
+// 172-289 cycles
 func (g *Video) EndMode3(cyclesLate uint64) {
+	g.ProcessDots(0)
+	if g.X < HORIZONTAL_PIXELS {
+		// OBJ fetch, SCX fine scroll and window extend mode 3
+		g.scheduler.ScheduleEvent(scheduler.EndMode3, g.EndMode3, uint64(HORIZONTAL_PIXELS-g.X))
+		return
+	}
+
+	length := uint64(g.Renderer.fifo.dot)
+	cyclesLate = g.scheduler.Cycle() - (g.dotClock + length)
+
 	oldStat := g.Stat
-	g.ProcessDots(cyclesLate)
 	g.hdma()
 	g.setMode(0)
-	g.scheduler.ScheduleEvent(scheduler.EndMode0, g.EndMode0, MODE_0_LENGTH-uint64(g.Renderer.objMax*6)-cyclesLate)
+	g.scheduler.ScheduleEvent(scheduler.EndMode0, g.EndMode0, MODE_0_LENGTH+MODE_3_LENGTH-length-cyclesLate)
 	if !statIRQAsserted(oldStat) && statIRQAsserted(g.Stat) {
 		g.io[GB_REG_IF] = util.SetBit8(g.io[GB_REG_IF], 1, true)
 		g.updateIRQs()
@@ -372,10 +390,30 @@ func (g *Video) updateFrameCount(_ uint64) {
 	g.FrameCounter++
 }
 
+// mGBA has only one mode event, so only one of EndModeX must be scheduled at a time
+func (g *Video) descheduleModeEvents() {
+	g.scheduler.DescheduleEvent(scheduler.EndMode0)
+	g.scheduler.DescheduleEvent(scheduler.EndMode1)
+	g.scheduler.DescheduleEvent(scheduler.EndMode2)
+	g.scheduler.DescheduleEvent(scheduler.EndMode3)
+}
+
 func (g *Video) Mode() byte {
 	return g.Stat & 0x3
 }
 
+// OAMRow returns OAM row (8 bytes, 2 objects) PPU is reading in OAM scan, -1 if not in mode 2
+func (g *Video) OAMRow() int {
+	if g.Mode() != 2 || !util.Bit(g.LCDC, Enable) {
+		return -1
+	}
+	until := g.scheduler.Until(scheduler.EndMode2)
+	if until == 0 || until > MODE_2_LENGTH {
+		return -1
+	}
+	return int(MODE_2_LENGTH-until) / 4
+}
+
 func (g *Video) setMode(mode byte) {
 	g.Stat = (g.Stat & 0xfc) | mode
 }
@@ -383,9 +421,11 @@ func (g *Video) setMode(mode byte) {
 // GBVideoWriteLCDC
 func (g *Video) WriteLCDC(old, value byte) {
 	if !util.Bit(old, Enable) && util.Bit(value, Enable) {
+		g.descheduleModeEvents()
 		g.scheduler.ScheduleEvent(scheduler.EndMode2, g.EndMode2, MODE_2_LENGTH-5)
 		g.Ly = 0
 		g.io[GB_REG_LY] = 0
+		g.Renderer.startFrame()
 		oldStat := g.Stat
 		g.setMode(0)
 		g.Stat = util.SetBit8(g.Stat, 2, byte(g.Ly) == g.io[GB_REG_LYC])
@@ -403,7 +443,7 @@ func (g *Video) WriteLCDC(old, value byte) {
 		g.io[GB_REG_LY] = 0
 		g.Renderer.writePalette(0, Color(g.dmgPalette[0]))
 
-		g.scheduler.DescheduleEvent(scheduler.EndMode0)
+		g.descheduleModeEvents()
 		g.scheduler.DescheduleEvent(scheduler.UpdateFrame)
 		g.scheduler.ScheduleEvent(scheduler.UpdateFrame, g.updateFrameCount, TOTAL_LENGTH)
 	}
@@ -0,0 +1,160 @@
+package gbs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	headerSize = 0x70
+	driverAddr = 0x0150 // player driver is placed after cartridge header
+	minLoad    = 0x0200
+)
+
+// GBS is Game Boy Sound System file
+//
+// ref: https://ocremix.org/info/GBS_Format_Specification
+type GBS struct {
+	Version   byte
+	Songs     int // number of songs
+	FirstSong int // 1-origin
+	Load      uint16
+	Init      uint16
+	Play      uint16
+	SP        uint16
+	TMA, TAC  byte
+	Title     string
+	Author    string
+	Copyright string
+	Code      []byte
+}
+
+// Parse parses GBS file
+func Parse(data []byte) (*GBS, error) {
+	if len(data) < headerSize || string(data[0:3]) != "GBS" {
+		return nil, errors.New("not GBS file")
+	}
+
+	g := &GBS{
+		Version:   data[0x03],
+		Songs:     int(data[0x04]),
+		FirstSong: int(data[0x05]),
+		Load:      binary.LittleEndian.Uint16(data[0x06:]),
+		Init:      binary.LittleEndian.Uint16(data[0x08:]),
+		Play:      binary.LittleEndian.Uint16(data[0x0a:]),
+		SP:        binary.LittleEndian.Uint16(data[0x0c:]),
+		TMA:       data[0x0e],
+		TAC:       data[0x0f],
+		Title:     header(data[0x10:0x30]),
+		Author:    header(data[0x30:0x50]),
+		Copyright: header(data[0x50:0x70]),
+		Code:      data[headerSize:],
+	}
+
+	switch {
+	case g.Version != 1:
+		return nil, fmt.Errorf("unsupported GBS version: %d", g.Version)
+	case g.Songs == 0:
+		return nil, errors.New("GBS has no song")
+	case g.Load < minLoad || g.Load >= 0x8000:
+		return nil, fmt.Errorf("unsupported load address: 0x%04x", g.Load)
+	case int(g.Load)+len(g.Code) > 0x4000*256:
+		return nil, errors.New("GBS code is too large")
+	}
+	if g.FirstSong < 1 || g.FirstSong > g.Songs {
+		g.FirstSong = 1
+	}
+	return g, nil
+}
+
+func header(b []byte) string {
+	return strings.TrimRight(string(b), "\x00")
+}
+
+// Timer reports whether PLAY is called on timer interrupt instead of VBlank
+func (g *GBS) Timer() bool { return g.TAC&0x04 != 0 }
+
+// DoubleSpeed reports whether GBS runs on CGB double speed mode
+func (g *GBS) DoubleSpeed() bool { return g.TAC&0x80 != 0 }
+
+// ROM builds minimal MBC5 cartridge which plays song(1-origin)
+//
+// Code is mapped at load address, RST vectors jump to load address + n, and
+// driver calls INIT, then calls PLAY on VBlank or timer interrupt.
+func (g *GBS) ROM(song int) []byte {
+	size, sizeCode := 0x8000, byte(0)
+	for size < int(g.Load)+len(g.Code) {
+		size, sizeCode = size*2, sizeCode+1
+	}
+
+	rom := make([]byte, size)
+	copy(rom[g.Load:], g.Code)
+
+	// RST vectors
+	for n := uint16(0); n < 0x40; n += 8 {
+		jp(rom[n:], g.Load+n)
+	}
+
+	// interrupt vectors
+	irqHandler := driverAddr + uint16(len(g.driver(song)))
+	jp(rom[0x40:], irqHandler) // VBlank
+	jp(rom[0x50:], irqHandler) // Timer
+
+	// cartridge header
+	jp(rom[0x100:], driverAddr)
+	copy(rom[0x134:], "GBS")
+	if g.DoubleSpeed() {
+		rom[0x143] = 0x80
+	}
+	rom[0x147], rom[0x148], rom[0x149] = 0x1a, sizeCode, 0x02 // MBC5+RAM, 8KB RAM
+
+	driver := append(g.driver(song), g.irqHandler()...)
+	copy(rom[driverAddr:], driver)
+	return rom
+}
+
+// driver initializes song and waits for interrupts
+func (g *GBS) driver(song int) []byte {
+	ie := byte(0x01) // VBlank
+	if g.Timer() {
+		ie = 0x04
+	}
+
+	code := []byte{
+		0xf3,                              // di
+		0x31, byte(g.SP), byte(g.SP >> 8), // ld sp, SP
+		0x3e, 0x0a, 0xea, 0x00, 0x00, // ld a, 0x0a; ld (0x0000), a (enable RAM)
+		0x3e, 0x01, 0xea, 0x00, 0x20, // ld a, 1; ld (0x2000), a (ROM bank 1)
+	}
+	if g.DoubleSpeed() {
+		code = append(code, 0x3e, 0x01, 0xe0, 0x4d, 0x10, 0x00) // ld a, 1; ldh (KEY1), a; stop
+	}
+	code = append(code,
+		0x3e, g.TMA, 0xe0, 0x06, // ld a, TMA; ldh (TMA), a
+		0x3e, g.TAC&0x07, 0xe0, 0x07, // ld a, TAC; ldh (TAC), a
+		0x3e, byte(song-1), // ld a, song
+		0xcd, byte(g.Init), byte(g.Init>>8), // call INIT
+		0x3e, ie, 0xe0, 0xff, // ld a, IE; ldh (IE), a
+		0xaf, 0xe0, 0x0f, // xor a; ldh (IF), a
+		0xfb,                   // ei
+		0x76, 0x00, 0x18, 0xfc, // halt; nop; jr -4
+	)
+	return code
+}
+
+// irqHandler calls PLAY
+func (g *GBS) irqHandler() []byte {
+	return []byte{
+		0xf5, 0xc5, 0xd5, 0xe5, // push af, bc, de, hl
+		0xcd, byte(g.Play), byte(g.Play >> 8), // call PLAY
+		0xe1, 0xd1, 0xc1, 0xf1, // pop hl, de, bc, af
+		0xd9, // reti
+	}
+}
+
+// jp writes `jp addr`
+func jp(b []byte, addr uint16) {
+	b[0], b[1], b[2] = 0xc3, byte(addr), byte(addr>>8)
+}
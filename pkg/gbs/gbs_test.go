@@ -0,0 +1,80 @@
+package gbs
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testGBS returns valid GBS file, edit is applied to header before return
+func testGBS(edit func(data []byte)) []byte {
+	data := make([]byte, headerSize+4)
+	copy(data, "GBS")
+	data[0x03] = 1                      // version
+	data[0x04], data[0x05] = 12, 3      // songs, first song
+	data[0x06], data[0x07] = 0x00, 0x04 // load
+	data[0x08], data[0x09] = 0x10, 0x04 // init
+	data[0x0a], data[0x0b] = 0x20, 0x04 // play
+	data[0x0c], data[0x0d] = 0xfe, 0xdf // SP
+	data[0x0e], data[0x0f] = 0xc0, 0x84 // TMA, TAC
+	copy(data[0x10:], "Title")
+	copy(data[0x30:], "Author")
+	copy(data[0x50:], "2021 Copyright")
+	copy(data[headerSize:], []byte{0xc9, 0x00, 0xc9, 0x00})
+	if edit != nil {
+		edit(data)
+	}
+	return data
+}
+
+func TestParse(t *testing.T) {
+	g, err := Parse(testGBS(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := GBS{
+		Version: 1, Songs: 12, FirstSong: 3,
+		Load: 0x400, Init: 0x410, Play: 0x420, SP: 0xdffe,
+		TMA: 0xc0, TAC: 0x84,
+		Title: "Title", Author: "Author", Copyright: "2021 Copyright",
+		Code: []byte{0xc9, 0x00, 0xc9, 0x00},
+	}
+	if !reflect.DeepEqual(*g, want) {
+		t.Errorf("got %+v, want %+v", *g, want)
+	}
+	if !g.Timer() || !g.DoubleSpeed() {
+		t.Errorf("Timer, DoubleSpeed = %v, %v, want true, true", g.Timer(), g.DoubleSpeed())
+	}
+}
+
+func TestParseFirstSong(t *testing.T) {
+	for _, tt := range []struct{ first, want int }{{0, 1}, {1, 1}, {12, 12}, {13, 1}} {
+		g, err := Parse(testGBS(func(data []byte) { data[0x05] = byte(tt.first) }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g.FirstSong != tt.want {
+			t.Errorf("first song %d: got %d, want %d", tt.first, g.FirstSong, tt.want)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"short header", testGBS(nil)[:headerSize-1]},
+		{"magic", testGBS(func(data []byte) { copy(data, "GBX") })},
+		{"version", testGBS(func(data []byte) { data[0x03] = 2 })},
+		{"no song", testGBS(func(data []byte) { data[0x04] = 0 })},
+		{"load in header", testGBS(func(data []byte) { data[0x06], data[0x07] = 0xff, 0x01 })},
+		{"load in RAM", testGBS(func(data []byte) { data[0x06], data[0x07] = 0x00, 0x80 })},
+		{"too large", append(testGBS(nil), make([]byte, 0x4000*256)...)},
+	} {
+		if _, err := Parse(tt.data); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package gbs
+
+import (
+	"github.com/pokemium/worldwide/pkg/gbc"
+)
+
+// FPS is frame rate of GameBoy
+const FPS = 4194304.0 / 70224
+
+// Player plays a song on gbc.GBC, so GBS is played with the same APU as games
+type Player struct {
+	Song   int
+	Length int // frames until the end of song, 0 means endless
+	Fade   int // frames to fade out before the end of song
+
+	gb      *gbc.GBC
+	frame   int
+	samples []int16
+}
+
+func (g *GBS) NewPlayer(song, sampleRate int) (*Player, error) {
+	noInput := func() bool { return false }
+	gb, err := gbc.New(g.ROM(song), [8]func() bool{noInput, noInput, noInput, noInput, noInput, noInput, noInput, noInput}, func([]int16) {})
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Player{Song: song, gb: gb}
+	gb.Sound.Enable = false
+	gb.Sound.SetSampleRate(sampleRate)
+	gb.Sound.SetRecord(func(mix []int16, stems [4][]int16) { p.samples = mix }, false)
+	return p, nil
+}
+
+// Frame runs 1 frame and returns signed 16bit interleaved stereo samples, ok is false after the end of song
+//
+// samples are reused on next Frame.
+func (p *Player) Frame() (samples []int16, ok bool, err error) {
+	if p.Length > 0 && p.frame >= p.Length {
+		return nil, false, nil
+	}
+
+	p.samples = nil
+	if err := p.gb.Update(); err != nil {
+		return nil, false, err
+	}
+	p.fade(p.samples)
+	p.frame++
+	return p.samples, true, nil
+}
+
+// fade fades out samples linearly in the last Fade frames
+func (p *Player) fade(samples []int16) {
+	if p.Length == 0 || p.Fade == 0 {
+		return
+	}
+
+	start := p.Length - p.Fade
+	n := len(samples) / 2
+	for i := 0; i < n; i++ {
+		pos := float64(p.frame) + float64(i)/float64(n) - float64(start)
+		if pos <= 0 {
+			continue
+		}
+		gain := 1 - pos/float64(p.Fade)
+		if gain < 0 {
+			gain = 0
+		}
+		samples[i*2] = int16(float64(samples[i*2]) * gain)
+		samples[i*2+1] = int16(float64(samples[i*2+1]) * gain)
+	}
+}
+
+// Seconds returns playing time
+func (p *Player) Seconds() float64 { return float64(p.frame) / FPS }
+
+// SetLength sets song length and fade-out length in seconds
+func (p *Player) SetLength(length, fade float64) {
+	p.Length, p.Fade = int(length*FPS), int(fade*FPS)
+	if p.Fade > p.Length {
+		p.Fade = p.Length
+	}
+}
@@ -0,0 +1,2 @@
+#!/bin/sh
+curl localhost:8888/audio/stats
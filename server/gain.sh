@@ -0,0 +1,2 @@
+#!/bin/sh
+curl "localhost:8888/audio/gain?ch=$1&v=$2"
@@ -0,0 +1,7 @@
+#!/bin/sh
+if [ $# != 2 ]; then
+    echo "please input button and frames(e.g. a 10)"
+    exit 1
+else
+    curl "localhost:8888/joypad/hold?button="$1"&frames="$2
+fi
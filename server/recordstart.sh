@@ -0,0 +1,2 @@
+#!/bin/sh
+curl "localhost:8888/audio/record/start?file=$1&stems=$2"
@@ -0,0 +1,2 @@
+#!/bin/sh
+curl localhost:8888/debug/violation
\ No newline at end of file
@@ -0,0 +1,7 @@
+#!/bin/sh
+if [ $# != 1 ]; then
+    echo "please input violation count(e.g. 0x20)"
+    exit 1
+else
+    curl -X POST -d '{"violation":"'$1'"}' -H "Content-Type: application/json" localhost:8888/debug/violation
+fi
\ No newline at end of file